@@ -21,6 +21,8 @@ import (
 	"github.com/jatis/sample-stack-golang/pkg/graceful"
 	"github.com/jatis/sample-stack-golang/pkg/infrastructure/metrics"
 	"github.com/jatis/sample-stack-golang/pkg/logger"
+	appMiddleware "github.com/jatis/sample-stack-golang/pkg/middleware"
+	"github.com/jatis/sample-stack-golang/pkg/ratelimit"
 	echoSwagger "github.com/swaggo/echo-swagger"
 )
 
@@ -128,8 +130,16 @@ func main() {
 
 	// Register routes
 	userHttp.RegisterRoutes(e, userHandler)
-	tenantHttp.RegisterRoutes(e, tenantHandler)
-	messageHandler.RegisterRoutes(e)
+	idempotency := appMiddleware.Idempotency(service.Redis, cfg.Idempotency.TTL)
+	publishLimit := appMiddleware.RateLimitConfig{
+		Limiter: ratelimit.NewLimiter(service.Redis, "ratelimit:publish:"),
+		Limits: tenantHttp.PublishLimits(service.TenantUseCase, ratelimit.Limit{
+			Rate:  cfg.RateLimit.PublishRate,
+			Burst: cfg.RateLimit.PublishBurst,
+		}),
+	}
+	tenantHttp.RegisterRoutes(e, tenantHandler, idempotency, publishLimit)
+	messageHandler.RegisterRoutes(e, idempotency, publishLimit)
 
 	// Start server in a goroutine
 	go func() {
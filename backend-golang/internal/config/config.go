@@ -2,6 +2,8 @@ package config
 
 import (
 	"fmt"
+	"time"
+
 	"github.com/spf13/viper"
 )
 
@@ -11,6 +13,11 @@ type Config struct {
 	DB       DBConfig
 	Redis    RedisConfig
 	RabbitMQ RabbitMQConfig
+	Consumer ConsumerConfig
+	Idempotency IdempotencyConfig
+	Dedup    DedupConfig
+	RateLimit RateLimitConfig `mapstructure:"rate_limit"`
+	Cluster  ClusterConfig
 	Logging  LoggingConfig
 	Server   ServerConfig
 }
@@ -22,6 +29,8 @@ type AppConfig struct {
 	Version string `mapstructure:"version"`
 	Env     string `mapstructure:"env"`
 	Workers int    `mapstructure:"workers"`
+	// Prefetch adalah default jumlah pesan unacked per consumer channel tenant
+	Prefetch int `mapstructure:"prefetch"`
 }
 
 // ServerConfig holds server configuration
@@ -67,6 +76,56 @@ type RabbitMQConfig struct {
 	Port     int    `mapstructure:"port"`
 	User     string `mapstructure:"user"`
 	Password string `mapstructure:"password"`
+	// FallbackHandler menentukan handler untuk pesan tanpa handler khusus:
+	// "persist" (default) menyimpan ke tabel messages, "dlq" langsung mengirim ke DLQ
+	FallbackHandler string `mapstructure:"fallback_handler"`
+	// ReconnectInitialInterval dan ReconnectMaxInterval mengatur exponential backoff
+	// saat koneksi RabbitMQ terputus dan perlu di-dial ulang
+	ReconnectInitialInterval time.Duration `mapstructure:"reconnect_initial_interval"`
+	ReconnectMaxInterval     time.Duration `mapstructure:"reconnect_max_interval"`
+}
+
+// ConsumerConfig holds tenant consumer health check configuration
+type ConsumerConfig struct {
+	// HealthCheckInterval adalah interval pemeriksaan kesehatan consumer
+	HealthCheckInterval time.Duration `mapstructure:"health_check_interval"`
+	// IdleThreshold: consumer tanpa heartbeat selama durasi ini dilaporkan idle (tidak di-restart)
+	IdleThreshold time.Duration `mapstructure:"idle_threshold"`
+	// StuckThreshold: pesan yang diproses lebih lama dari durasi ini membuat consumer dianggap stuck dan dibuat ulang
+	StuckThreshold time.Duration `mapstructure:"stuck_threshold"`
+	// ReconcileInterval adalah interval pencocokan tabel tenants, consumer yang berjalan, dan queue di broker
+	ReconcileInterval time.Duration `mapstructure:"reconcile_interval"`
+}
+
+// IdempotencyConfig holds Idempotency-Key configuration for publish endpoints
+type IdempotencyConfig struct {
+	// TTL adalah lama response disimpan di Redis untuk replay request dengan key yang sama
+	TTL time.Duration `mapstructure:"ttl"`
+}
+
+// ClusterConfig holds multi-replica consumer ownership configuration
+type ClusterConfig struct {
+	// LeasesEnabled mengaktifkan lease Redis sehingga consumer setiap tenant hanya berjalan
+	// di satu replica. Jika false, setiap replica menjalankan consumer untuk semua tenant.
+	LeasesEnabled bool `mapstructure:"leases_enabled"`
+	// ReplicaID harus unik per replica backend; kosong berarti memakai hostname
+	ReplicaID string `mapstructure:"replica_id"`
+	// LeaseTTL: replica lain mengambil alih tenant jika lease tidak diperpanjang selama durasi ini
+	LeaseTTL time.Duration `mapstructure:"lease_ttl"`
+}
+
+// DedupConfig holds consumer-side message deduplication configuration
+type DedupConfig struct {
+	// TTL adalah jendela deduplikasi: MessageId yang sudah diproses diingat selama durasi ini
+	TTL time.Duration `mapstructure:"ttl"`
+}
+
+// RateLimitConfig holds the default per-tenant publish rate limit
+type RateLimitConfig struct {
+	// PublishRate adalah default jumlah pesan per detik untuk tenant tanpa publish_rate (0 = tidak dibatasi)
+	PublishRate float64 `mapstructure:"publish_rate"`
+	// PublishBurst adalah default kapasitas token bucket (0 = sama dengan PublishRate)
+	PublishBurst int `mapstructure:"publish_burst"`
 }
 
 // LoggingConfig holds logging configuration
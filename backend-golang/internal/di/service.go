@@ -3,11 +3,11 @@ package di
 import (
 	"context"
 	"fmt"
+	"os"
 	"time"
 
 	"github.com/go-redis/redis/v8"
 	"github.com/jackc/pgx/v5/pgxpool"
-	"github.com/streadway/amqp"
 
 	"github.com/jatis/sample-stack-golang/internal/config"
 	"github.com/jatis/sample-stack-golang/internal/modules/user/domain"
@@ -17,9 +17,13 @@ import (
 	tenantRepo "github.com/jatis/sample-stack-golang/internal/modules/tenant/repository/postgresql"
 	tenantUsecase "github.com/jatis/sample-stack-golang/internal/modules/tenant/usecase"
 	tenantRabbitMQ "github.com/jatis/sample-stack-golang/internal/modules/tenant/delivery/messaging/rabbitmq"
+	tenantConsumer "github.com/jatis/sample-stack-golang/internal/modules/tenant/delivery/messaging/rabbitmq/consumer"
+	messageDomain "github.com/jatis/sample-stack-golang/internal/modules/message/domain"
 	messageRepo "github.com/jatis/sample-stack-golang/internal/modules/message/repository/postgresql"
 	messageUsecase "github.com/jatis/sample-stack-golang/internal/modules/message/usecase"
+	"github.com/jatis/sample-stack-golang/pkg/lease"
 	"github.com/jatis/sample-stack-golang/pkg/logger"
+	pkgRabbitMQ "github.com/jatis/sample-stack-golang/pkg/rabbitmq"
 )
 
 // ServiceContainer adalah interface untuk mengakses service
@@ -84,7 +88,7 @@ type Service struct {
 	Config        *config.Config
 	Pool          *pgxpool.Pool
 	Redis         *redis.Client
-	RabbitMQ      *amqp.Connection
+	RabbitMQ      *pkgRabbitMQ.Connection
 	UserUseCase   domain.UserUseCase
 	TenantUseCase tenantDomain.TenantUseCase
 	MessageUseCase *messageUsecase.MessageUsecase
@@ -118,8 +122,31 @@ func NewService(cfg *config.Config) (*Service, error) {
 	tenantRepo := tenantRepo.NewTenantRepository(pool, cfg)
 	messageRepo := messageRepo.NewMessageRepository(pool)
 
+	// Initialize message handler registry for tenant workers
+	messageHandlers := initMessageHandlers(cfg, messageRepo)
+
+	// Redis deduplication stage for tenants with dedup_enabled
+	deduplicator := tenantConsumer.NewDeduplicator(redis, cfg.Dedup.TTL)
+
+	// Lease-based ownership so each tenant consumer runs on one replica only
+	var leases *lease.Locker
+	if cfg.Cluster.LeasesEnabled {
+		replica, err := replicaID(cfg)
+		if err != nil {
+			pool.Close() // Cleanup database connection
+			redis.Close() // Cleanup Redis connection
+			rabbitmq.Close() // Cleanup RabbitMQ connection
+			return nil, fmt.Errorf("failed to determine replica ID: %v", err)
+		}
+		leases = lease.NewLocker(redis, "lease:tenant:", replica, cfg.Cluster.LeaseTTL)
+		logger.Log.WithFields(map[string]interface{}{
+			"replica":   replica,
+			"lease_ttl": leases.TTL(),
+		}).Info("Tenant consumer leases enabled")
+	}
+
 	// Initialize RabbitMQ tenant manager
-	tenantManager := tenantRabbitMQ.NewTenantManager(rabbitmq, pool)
+	tenantManager := tenantRabbitMQ.NewTenantManager(rabbitmq, pool, messageHandlers, deduplicator, leases, cfg.Consumer)
 
 	// Initialize usecases
 	userUseCase := userUsecase.NewUserUseCase(userRepo)
@@ -224,8 +251,19 @@ func initRedis(cfg *config.Config) (*redis.Client, error) {
 	return client, nil
 }
 
-// initRabbitMQ initializes RabbitMQ connection
-func initRabbitMQ(cfg *config.Config) (*amqp.Connection, error) {
+// initMessageHandlers initializes the handler registry used by tenant workers.
+// Tenant- or type-specific handlers can be registered here.
+func initMessageHandlers(cfg *config.Config, messageRepo messageDomain.MessageRepository) *tenantConsumer.HandlerRegistry {
+	var fallback tenantDomain.MessageHandler
+	if cfg.RabbitMQ.FallbackHandler != "dlq" {
+		fallback = tenantConsumer.NewPersistHandler(messageRepo)
+	}
+
+	return tenantConsumer.NewHandlerRegistry(fallback)
+}
+
+// initRabbitMQ initializes RabbitMQ connection that reconnects automatically when lost
+func initRabbitMQ(cfg *config.Config) (*pkgRabbitMQ.Connection, error) {
 	dsn := fmt.Sprintf("amqp://%s:%s@%s:%d/",
 		cfg.RabbitMQ.User,
 		cfg.RabbitMQ.Password,
@@ -233,10 +271,26 @@ func initRabbitMQ(cfg *config.Config) (*amqp.Connection, error) {
 		cfg.RabbitMQ.Port,
 	)
 
-	conn, err := amqp.Dial(dsn)
+	conn, err := pkgRabbitMQ.Dial(dsn, cfg.RabbitMQ.ReconnectInitialInterval, cfg.RabbitMQ.ReconnectMaxInterval)
 	if err != nil {
 		return nil, err
 	}
 
 	return conn, nil
-} 
\ No newline at end of file
+} 
+
+// replicaID mengembalikan ID replica untuk lease consumer tenant; default hostname.
+// Tidak ada fallback lain karena dua replica dengan ID sama dianggap satu pemilik lease.
+func replicaID(cfg *config.Config) (string, error) {
+	if cfg.Cluster.ReplicaID != "" {
+		return cfg.Cluster.ReplicaID, nil
+	}
+
+	hostname, err := os.Hostname()
+	if err != nil || hostname == "" {
+		return "", fmt.Errorf("cluster.replica_id is not set and hostname is unavailable: %v", err)
+	}
+
+	logger.Log.WithField("replica", hostname).Warn("cluster.replica_id is not set, using hostname as replica ID")
+	return hostname, nil
+}
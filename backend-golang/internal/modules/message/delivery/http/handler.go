@@ -29,9 +29,11 @@ func NewMessageHandler(messageUsecase *usecase.MessageUsecase) *MessageHandler {
 // @Accept json
 // @Produce json
 // @Param tenant_id path string true "Tenant ID"
+// @Param Idempotency-Key header string false "Replays the original response for retried requests"
 // @Param message body domain.Message true "Message Information"
 // @Success 201 {object} domain.Message
 // @Failure 400 {object} map[string]string
+// @Failure 429 {object} map[string]string
 // @Failure 500 {object} map[string]string
 // @Router /tenants/{tenant_id}/messages [post]
 func (h *MessageHandler) Create(c echo.Context) error {
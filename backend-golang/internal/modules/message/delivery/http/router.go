@@ -2,13 +2,19 @@ package http
 
 import (
 	"github.com/labstack/echo/v4"
+
+	appMiddleware "github.com/jatis/sample-stack-golang/pkg/middleware"
 )
 
-// RegisterRoutes registers all message routes
-func (h *MessageHandler) RegisterRoutes(e *echo.Echo) {
+// RegisterRoutes registers all message routes. The idempotency middleware is
+// applied to message creation so requests carrying an Idempotency-Key are replayed,
+// and publishLimit (Limiter and Limits) rate limits message creation per tenant.
+func (h *MessageHandler) RegisterRoutes(e *echo.Echo, idempotency echo.MiddlewareFunc, publishLimit appMiddleware.RateLimitConfig) {
+	publishLimit.Param = "tenant_id"
+
 	// Tenant-specific message routes
 	messageGroup := e.Group("/api/tenants/:tenant_id/messages")
-	messageGroup.POST("", h.Create)
+	messageGroup.POST("", h.Create, idempotency, appMiddleware.RateLimit(publishLimit))
 	messageGroup.GET("", h.GetByTenant)
 	messageGroup.GET("/:id", h.GetByID)
 	messageGroup.PUT("/:id", h.Update)
@@ -41,9 +41,11 @@ func (r *MessageRepository) Create(ctx context.Context, message *domain.Message)
 		return err
 	}
 
+	// ON CONFLICT membuat insert idempotent untuk pesan yang di-redeliver
 	query := `
 		INSERT INTO messages (id, tenant_id, payload, created_at, updated_at)
 		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (tenant_id, id) DO NOTHING
 	`
 
 	_, err = r.db.Exec(ctx, query,
@@ -0,0 +1,24 @@
+package http
+
+import (
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+)
+
+// GetReconcileReport handles getting the last tenant reconcile report
+// @Summary Get last reconcile report
+// @Description Get the result of the last periodic reconciliation between the tenants table, the consumers running in this replica and the tenant queues on the broker: consumers started or stopped, queues recreated and errors
+// @Tags admin
+// @Produce json
+// @Success 200 {object} domain.ReconcileReport
+// @Failure 404 {object} map[string]string
+// @Router /admin/reconcile [get]
+func (h *TenantHandler) GetReconcileReport(c echo.Context) error {
+	report := h.tenantUseCase.LastReconcileReport()
+	if report == nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "no reconcile has run yet"})
+	}
+
+	return c.JSON(http.StatusOK, report)
+}
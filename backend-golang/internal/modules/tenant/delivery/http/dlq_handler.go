@@ -1,12 +1,18 @@
 package http
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
+	"strconv"
 
+	"github.com/jatis/sample-stack-golang/internal/modules/tenant/domain"
 	"github.com/jatis/sample-stack-golang/pkg/infrastructure/metrics"
 	"github.com/jatis/sample-stack-golang/pkg/logger"
+	"github.com/jatis/sample-stack-golang/pkg/rabbitmq"
 	"github.com/labstack/echo/v4"
+	"github.com/streadway/amqp"
 )
 
 // GetDLQStatus handles getting dead-letter queue status for a tenant
@@ -69,6 +75,286 @@ func (h *TenantHandler) GetDLQStatus(c echo.Context) error {
 	})
 }
 
+// GetDLQMessages handles browsing dead-lettered messages for a tenant
+// @Summary Browse DLQ messages
+// @Description Peek dead-lettered messages for a tenant without removing them from the DLQ
+// @Tags tenants
+// @Accept json
+// @Produce json
+// @Param id path string true "Tenant ID"
+// @Param limit query int false "Number of messages to return (default: 10, max: 100)"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /tenants/{id}/dlq/messages [get]
+func (h *TenantHandler) GetDLQMessages(c echo.Context) error {
+	tenantID := c.Param("id")
+	if tenantID == "" {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "tenant ID is required"})
+	}
+
+	// Parse limit from query param, default to 10 if not provided or invalid
+	limit, err := strconv.Atoi(c.QueryParam("limit"))
+	if err != nil || limit <= 0 || limit > 100 {
+		limit = 10 // default limit
+	}
+
+	// Gunakan channel khusus karena pesan tetap unacked selama proses peek
+	ch, err := h.tenantUseCase.GetChannel()
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "failed to get channel"})
+	}
+	defer ch.Close()
+
+	dlqName := rabbitmq.NewDefaultDeadLetterConfig().DeadLetterQueueName(tenantID)
+	if _, err := ch.QueueInspect(dlqName); err != nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "dead-letter queue not found"})
+	}
+
+	deliveries, err := rabbitmq.PeekMessages(ch, dlqName, limit)
+	if err != nil {
+		logger.Log.WithFields(map[string]interface{}{
+			"tenant_id": tenantID,
+			"queue":     dlqName,
+			"error":     err,
+		}).Error("[DLQ] Failed to peek DLQ messages")
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "failed to read dead-letter queue"})
+	}
+
+	messages := make([]*domain.DLQMessage, 0, len(deliveries))
+	for _, d := range deliveries {
+		messages = append(messages, toDLQMessage(d))
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"tenant_id": tenantID,
+		"queue":     dlqName,
+		"count":     len(messages),
+		"data":      messages,
+	})
+}
+
+// ReplayDLQ handles moving dead-lettered messages back to the tenant queue
+// @Summary Replay DLQ messages
+// @Description Move messages from the tenant DLQ back to the main queue with the retry header reset
+// @Tags tenants
+// @Accept json
+// @Produce json
+// @Param id path string true "Tenant ID"
+// @Param limit query int false "Maximum number of messages to replay (default: all)"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]interface{}
+// @Failure 500 {object} map[string]string
+// @Failure 503 {object} map[string]interface{}
+// @Router /tenants/{id}/dlq/replay [post]
+func (h *TenantHandler) ReplayDLQ(c echo.Context) error {
+	tenantID := c.Param("id")
+	if tenantID == "" {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "tenant ID is required"})
+	}
+
+	limit := 0 // replay all messages
+	if raw := c.QueryParam("limit"); raw != "" {
+		parsed, err := strconv.Atoi(raw)
+		if err != nil || parsed < 0 {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid limit"})
+		}
+		limit = parsed
+	}
+
+	ch, err := h.tenantUseCase.GetChannel()
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "failed to get channel"})
+	}
+	defer ch.Close()
+
+	dlConfig := rabbitmq.NewDefaultDeadLetterConfig()
+	if _, err := ch.QueueInspect(dlConfig.DeadLetterQueueName(tenantID)); err != nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "dead-letter queue not found"})
+	}
+
+	replayed, err := rabbitmq.ReplayDeadLetters(ch, tenantID, dlConfig, limit)
+	if err != nil {
+		logger.Log.WithFields(map[string]interface{}{
+			"tenant_id": tenantID,
+			"replayed":  replayed,
+			"error":     err,
+		}).Error("[DLQ] Failed to replay DLQ messages")
+		status := http.StatusInternalServerError
+		switch {
+		case errors.Is(err, rabbitmq.ErrUnroutable):
+			// Main queue tenant tidak ada; pesan tetap di DLQ
+			status = http.StatusConflict
+		case errors.Is(err, rabbitmq.ErrConfirmTimeout):
+			// Broker tidak mengonfirmasi replay; pesan dikembalikan ke DLQ
+			status = http.StatusServiceUnavailable
+		}
+		return c.JSON(status, map[string]interface{}{
+			"error":    err.Error(),
+			"replayed": replayed,
+		})
+	}
+
+	logger.Log.WithFields(map[string]interface{}{
+		"tenant_id": tenantID,
+		"replayed":  replayed,
+	}).Info("[DLQ] DLQ messages replayed")
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"message":   "DLQ messages replayed successfully",
+		"tenant_id": tenantID,
+		"replayed":  replayed,
+	})
+}
+
+// ReplayDLQMessage handles moving a single dead-lettered message back to the tenant queue
+// @Summary Replay a DLQ message
+// @Description Move one message, identified by its message ID, from the tenant DLQ back to the main queue
+// @Tags tenants
+// @Accept json
+// @Produce json
+// @Param id path string true "Tenant ID"
+// @Param message_id path string true "Message ID"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Failure 503 {object} map[string]string
+// @Router /tenants/{id}/dlq/messages/{message_id}/replay [post]
+func (h *TenantHandler) ReplayDLQMessage(c echo.Context) error {
+	tenantID := c.Param("id")
+	messageID := c.Param("message_id")
+	if tenantID == "" || messageID == "" {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "tenant ID and message ID are required"})
+	}
+
+	ch, err := h.tenantUseCase.GetChannel()
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "failed to get channel"})
+	}
+	defer ch.Close()
+
+	dlConfig := rabbitmq.NewDefaultDeadLetterConfig()
+	if _, err := ch.QueueInspect(dlConfig.DeadLetterQueueName(tenantID)); err != nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "dead-letter queue not found"})
+	}
+
+	found, err := rabbitmq.ReplayDeadLetter(ch, tenantID, dlConfig, messageID)
+	if err != nil {
+		logger.Log.WithFields(map[string]interface{}{
+			"tenant_id":  tenantID,
+			"message_id": messageID,
+			"error":      err,
+		}).Error("[DLQ] Failed to replay DLQ message")
+		if errors.Is(err, rabbitmq.ErrUnroutable) {
+			// Main queue tenant tidak ada; pesan tetap di DLQ
+			return c.JSON(http.StatusConflict, map[string]string{"error": err.Error()})
+		}
+		if errors.Is(err, rabbitmq.ErrConfirmTimeout) {
+			// Broker tidak mengonfirmasi replay; pesan dikembalikan ke DLQ
+			return c.JSON(http.StatusServiceUnavailable, map[string]string{"error": err.Error()})
+		}
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+	if !found {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "message not found in dead-letter queue"})
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"message":    "DLQ message replayed successfully",
+		"tenant_id":  tenantID,
+		"message_id": messageID,
+	})
+}
+
+// PurgeDLQ handles deleting all dead-lettered messages for a tenant
+// @Summary Purge DLQ
+// @Description Delete all messages from the tenant DLQ
+// @Tags tenants
+// @Accept json
+// @Produce json
+// @Param id path string true "Tenant ID"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /tenants/{id}/dlq [delete]
+func (h *TenantHandler) PurgeDLQ(c echo.Context) error {
+	tenantID := c.Param("id")
+	if tenantID == "" {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "tenant ID is required"})
+	}
+
+	ch, err := h.tenantUseCase.GetChannel()
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "failed to get channel"})
+	}
+	defer ch.Close()
+
+	dlConfig := rabbitmq.NewDefaultDeadLetterConfig()
+	if _, err := ch.QueueInspect(dlConfig.DeadLetterQueueName(tenantID)); err != nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "dead-letter queue not found"})
+	}
+
+	purged, err := rabbitmq.PurgeDeadLetters(ch, tenantID, dlConfig)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+
+	logger.Log.WithFields(map[string]interface{}{
+		"tenant_id": tenantID,
+		"purged":    purged,
+	}).Info("[DLQ] DLQ purged")
+
+	// DLQ kosong setelah purge
+	metrics.UpdateDLQMetrics(tenantID, 0)
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"message":   "DLQ purged successfully",
+		"tenant_id": tenantID,
+		"purged":    purged,
+	})
+}
+
+// toDLQMessage mengubah delivery dari DLQ menjadi representasi API
+func toDLQMessage(d amqp.Delivery) *domain.DLQMessage {
+	body := json.RawMessage(d.Body)
+	if !json.Valid(d.Body) {
+		// Body bukan JSON, kirim sebagai string
+		body, _ = json.Marshal(string(d.Body))
+	}
+
+	headers := make(map[string]interface{}, len(d.Headers))
+	for k, v := range d.Headers {
+		headers[k] = v
+	}
+
+	deaths := make([]map[string]interface{}, 0)
+	if entries, ok := d.Headers["x-death"].([]interface{}); ok {
+		for _, entry := range entries {
+			if table, ok := entry.(amqp.Table); ok {
+				deaths = append(deaths, map[string]interface{}(table))
+			}
+		}
+	}
+
+	return &domain.DLQMessage{
+		MessageID:     d.MessageId,
+		CorrelationID: d.CorrelationId,
+		Type:          d.Type,
+		Timestamp:     d.Timestamp,
+		Body:          body,
+		Headers:       headers,
+		Deaths:        deaths,
+		RetryCount:    rabbitmq.GetRetryCount(d.Headers),
+		LastError:     rabbitmq.GetLastError(d.Headers),
+	}
+}
+
 // ActivateConsumer handles activating a consumer for a tenant
 func (h *TenantHandler) ActivateConsumer(c echo.Context) error {
 	tenantID := c.Param("id")
@@ -1,18 +1,22 @@
 package http
 
 import (
-	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"net/http"
 	"time"
 
 	"github.com/jatis/sample-stack-golang/internal/modules/tenant/domain"
+	"github.com/jatis/sample-stack-golang/internal/modules/tenant/usecase"
 	"github.com/jatis/sample-stack-golang/pkg/infrastructure/metrics"
 	"github.com/jatis/sample-stack-golang/pkg/logger"
 	"github.com/labstack/echo/v4"
-	"github.com/streadway/amqp"
 )
 
+// defaultDrainTimeout is used when DELETE ?mode=drain is called without a timeout
+const defaultDrainTimeout = 60 * time.Second
+
 // TenantHandler handles HTTP requests for tenants
 type TenantHandler struct {
 	tenantUseCase domain.TenantUseCase
@@ -74,7 +78,7 @@ func (h *TenantHandler) GetByID(c echo.Context) error {
 
 // Update handles tenant updates
 // @Summary Update a tenant
-// @Description Update an existing tenant's information
+// @Description Update an existing tenant's information. A changed status is applied as a validated status transition.
 // @Tags tenants
 // @Accept json
 // @Produce json
@@ -82,6 +86,8 @@ func (h *TenantHandler) GetByID(c echo.Context) error {
 // @Param tenant body domain.Tenant true "Updated Tenant Information"
 // @Success 200 {object} domain.Tenant
 // @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
 // @Failure 500 {object} map[string]string
 // @Router /tenants/{id} [put]
 func (h *TenantHandler) Update(c echo.Context) error {
@@ -95,7 +101,7 @@ func (h *TenantHandler) Update(c echo.Context) error {
 	tenant.UpdatedAt = time.Now()
 
 	if err := h.tenantUseCase.Update(c.Request().Context(), &tenant); err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return tenantStatusErrorResponse(c, err)
 	}
 
 	return c.JSON(http.StatusOK, tenant)
@@ -103,16 +109,31 @@ func (h *TenantHandler) Update(c echo.Context) error {
 
 // Delete handles tenant deletion
 // @Summary Delete a tenant
-// @Description Delete a tenant from the system
+// @Description Delete a tenant from the system. With mode=drain the tenant is marked as deleting, new publishes are refused and the tenant is removed once its backlog is processed.
 // @Tags tenants
 // @Accept json
 // @Produce json
 // @Param id path string true "Tenant ID"
+// @Param mode query string false "Deletion mode: immediate (default) or drain"
+// @Param timeout query string false "Drain timeout as a duration, e.g. 60s (default: 60s)"
+// @Success 202 {object} domain.Tenant
 // @Success 204 "No Content"
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
 // @Failure 500 {object} map[string]string
 // @Router /tenants/{id} [delete]
 func (h *TenantHandler) Delete(c echo.Context) error {
 	id := c.Param("id")
+
+	switch mode := c.QueryParam("mode"); mode {
+	case "", "immediate":
+	case "drain":
+		return h.deleteWithDrain(c, id)
+	default:
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": fmt.Sprintf("invalid deletion mode %q", mode)})
+	}
+
 	if err := h.tenantUseCase.Delete(c.Request().Context(), id); err != nil {
 		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
 	}
@@ -120,6 +141,30 @@ func (h *TenantHandler) Delete(c echo.Context) error {
 	return c.NoContent(http.StatusNoContent)
 }
 
+// deleteWithDrain starts a drain-then-delete for the tenant and returns its current state
+func (h *TenantHandler) deleteWithDrain(c echo.Context, id string) error {
+	timeout := defaultDrainTimeout
+	if raw := c.QueryParam("timeout"); raw != "" {
+		parsed, err := time.ParseDuration(raw)
+		if err != nil || parsed <= 0 {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid timeout, expected a duration such as 60s"})
+		}
+		timeout = parsed
+	}
+
+	ctx := c.Request().Context()
+	if err := h.tenantUseCase.DeleteWithDrain(ctx, id, timeout); err != nil {
+		return tenantStatusErrorResponse(c, err)
+	}
+
+	tenant, err := h.tenantUseCase.GetByID(ctx, id)
+	if err != nil {
+		return c.JSON(http.StatusAccepted, map[string]string{"message": "Tenant deletion started"})
+	}
+
+	return c.JSON(http.StatusAccepted, tenant)
+}
+
 // List handles listing all tenants
 // @Summary List all tenants
 // @Description Get a list of all tenants in the system
@@ -140,7 +185,7 @@ func (h *TenantHandler) List(c echo.Context) error {
 
 // CreateTenant handles tenant creation with consumer
 // @Summary Create tenant with consumer
-// @Description Create a new tenant and start its message consumer
+// @Description Create a new tenant and provision its queues and message consumer. If provisioning fails the tenant is returned with status failed and a status_reason, and provisioning is retried in the background.
 // @Tags tenants
 // @Accept json
 // @Produce json
@@ -162,11 +207,7 @@ func (h *TenantHandler) CreateTenant(c echo.Context) error {
 		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
 	}
 
-	// Start consumer for new tenant
-	if err := h.tenantUseCase.StartConsumer(c.Request().Context(), tenant.ID); err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
-	}
-
+	// Create sudah menjalankan provisioning; tenant failed berisi status_reason dan dicoba ulang oleh reconciler
 	return c.JSON(http.StatusCreated, tenant)
 }
 
@@ -286,6 +327,11 @@ func (h *TenantHandler) UpdateConcurrency(c echo.Context) error {
 		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Worker count must be greater than 0"})
 	}
 
+	// Validate prefetch (0 keeps the current value)
+	if config.Prefetch < 0 {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Prefetch must not be negative"})
+	}
+
 	// Update concurrency configuration
 	if err := h.tenantUseCase.UpdateConcurrency(c.Request().Context(), id, &config); err != nil {
 		if err.Error() == "tenant not found" {
@@ -298,9 +344,218 @@ func (h *TenantHandler) UpdateConcurrency(c echo.Context) error {
 		"message":   "Concurrency configuration updated successfully",
 		"tenant_id": id,
 		"workers":   config.Workers,
+		"prefetch":  config.Prefetch,
+	})
+}
+
+// UpdateDedup handles enabling or disabling consumer-side deduplication for a tenant
+// @Summary Update tenant deduplication
+// @Description Enable or disable skipping of messages whose MessageId was already processed (tracked in Redis)
+// @Tags tenants
+// @Accept json
+// @Produce json
+// @Param id path string true "Tenant ID"
+// @Param config body domain.DedupConfig true "Deduplication Configuration"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /tenants/{id}/config/dedup [put]
+func (h *TenantHandler) UpdateDedup(c echo.Context) error {
+	id := c.Param("id")
+
+	var config domain.DedupConfig
+	if err := c.Bind(&config); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request format"})
+	}
+
+	if err := h.tenantUseCase.UpdateDedup(c.Request().Context(), id, &config); err != nil {
+		if errors.Is(err, usecase.ErrTenantNotFound) {
+			return c.JSON(http.StatusNotFound, map[string]string{"error": "Tenant not found"})
+		}
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"message":       "Deduplication configuration updated successfully",
+		"tenant_id":     id,
+		"dedup_enabled": config.Enabled,
 	})
 }
 
+// UpdatePublishLimit handles updating the publish rate limit of a tenant
+// @Summary Update tenant publish rate limit
+// @Description Set the token bucket rate limit (messages per second and burst) shared by the publish, batch publish and message create endpoints. A rate of 0 uses the configured default.
+// @Tags tenants
+// @Accept json
+// @Produce json
+// @Param id path string true "Tenant ID"
+// @Param config body domain.PublishLimitConfig true "Publish Rate Limit"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /tenants/{id}/config/publish-limit [put]
+func (h *TenantHandler) UpdatePublishLimit(c echo.Context) error {
+	id := c.Param("id")
+
+	var config domain.PublishLimitConfig
+	if err := c.Bind(&config); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request format"})
+	}
+
+	if config.Rate < 0 || config.Burst < 0 {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Rate and burst must not be negative"})
+	}
+
+	if err := h.tenantUseCase.UpdatePublishLimit(c.Request().Context(), id, &config); err != nil {
+		if errors.Is(err, usecase.ErrTenantNotFound) {
+			return c.JSON(http.StatusNotFound, map[string]string{"error": "Tenant not found"})
+		}
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"message":       "Publish rate limit updated successfully",
+		"tenant_id":     id,
+		"publish_rate":  config.Rate,
+		"publish_burst": config.Burst,
+	})
+}
+
+// UpdateThroughput handles updating the consumption rate limit of a tenant
+// @Summary Update tenant throughput
+// @Description Cap how many messages per second the tenant workers process, e.g. to respect downstream quotas. A rate of 0 removes the limit.
+// @Tags tenants
+// @Accept json
+// @Produce json
+// @Param id path string true "Tenant ID"
+// @Param config body domain.ThroughputConfig true "Throughput Configuration"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /tenants/{id}/config/throughput [put]
+func (h *TenantHandler) UpdateThroughput(c echo.Context) error {
+	id := c.Param("id")
+
+	var config domain.ThroughputConfig
+	if err := c.Bind(&config); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request format"})
+	}
+
+	if config.Rate < 0 || config.Burst < 0 {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Rate and burst must not be negative"})
+	}
+
+	if err := h.tenantUseCase.UpdateThroughput(c.Request().Context(), id, &config); err != nil {
+		if errors.Is(err, usecase.ErrTenantNotFound) {
+			return c.JSON(http.StatusNotFound, map[string]string{"error": "Tenant not found"})
+		}
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"message":          "Throughput configuration updated successfully",
+		"tenant_id":        id,
+		"throughput_rate":  config.Rate,
+		"throughput_burst": config.Burst,
+	})
+}
+
+// Pause handles pausing consumption for a tenant
+// @Summary Pause tenant consumer
+// @Description Stop consuming messages for a tenant. The queue, DLQ and consumer are kept, messages keep accumulating and the paused status survives restarts.
+// @Tags tenants
+// @Produce json
+// @Param id path string true "Tenant ID"
+// @Success 200 {object} map[string]interface{}
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /tenants/{id}/pause [post]
+func (h *TenantHandler) Pause(c echo.Context) error {
+	id := c.Param("id")
+
+	if err := h.tenantUseCase.Pause(c.Request().Context(), id); err != nil {
+		return tenantStatusErrorResponse(c, err)
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"message":   "Consumer paused successfully",
+		"tenant_id": id,
+		"status":    domain.TenantStatusPaused,
+	})
+}
+
+// Resume handles resuming consumption for a paused tenant
+// @Summary Resume tenant consumer
+// @Description Resume consuming messages for a paused tenant
+// @Tags tenants
+// @Produce json
+// @Param id path string true "Tenant ID"
+// @Success 200 {object} map[string]interface{}
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /tenants/{id}/resume [post]
+func (h *TenantHandler) Resume(c echo.Context) error {
+	id := c.Param("id")
+
+	if err := h.tenantUseCase.Resume(c.Request().Context(), id); err != nil {
+		return tenantStatusErrorResponse(c, err)
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"message":   "Consumer resumed successfully",
+		"tenant_id": id,
+		"status":    domain.TenantStatusActive,
+	})
+}
+
+// UpdateStatus handles tenant status transitions
+// @Summary Change tenant status
+// @Description Move a tenant to active, paused or suspended. Active starts or resumes the consumer, paused stops consumption while publishes keep accumulating, and suspended stops consumption and refuses new publishes. Invalid transitions return 409.
+// @Tags tenants
+// @Accept json
+// @Produce json
+// @Param id path string true "Tenant ID"
+// @Param status body domain.TenantStatusUpdate true "Target status"
+// @Success 200 {object} domain.Tenant
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /tenants/{id}/status [put]
+func (h *TenantHandler) UpdateStatus(c echo.Context) error {
+	id := c.Param("id")
+
+	var req domain.TenantStatusUpdate
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+
+	tenant, err := h.tenantUseCase.Transition(c.Request().Context(), id, req.Status)
+	if err != nil {
+		return tenantStatusErrorResponse(c, err)
+	}
+
+	return c.JSON(http.StatusOK, tenant)
+}
+
+// tenantStatusErrorResponse maps status transition errors to HTTP responses
+func tenantStatusErrorResponse(c echo.Context, err error) error {
+	switch {
+	case errors.Is(err, usecase.ErrTenantNotFound):
+		return c.JSON(http.StatusNotFound, map[string]string{"error": err.Error()})
+	case errors.Is(err, usecase.ErrInvalidInput):
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	case errors.Is(err, usecase.ErrTenantDeleting), errors.Is(err, usecase.ErrInvalidTransition):
+		return c.JSON(http.StatusConflict, map[string]string{"error": err.Error()})
+	}
+	return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+}
+
 // GetQueueStatus handles getting queue status for a tenant
 func (h *TenantHandler) GetQueueStatus(c echo.Context) error {
 	tenantID := c.Param("id")
@@ -334,6 +589,12 @@ func (h *TenantHandler) GetQueueStatus(c echo.Context) error {
 	// Update with actual values if consumer exists
 	if consumer != nil {
 		status = "active"
+		if consumer.Paused.Load() {
+			status = domain.TenantStatusPaused
+			if tenant, err := h.tenantUseCase.GetByID(c.Request().Context(), tenantID); err == nil && tenant.Status == domain.TenantStatusSuspended {
+				status = domain.TenantStatusSuspended
+			}
+		}
 		workers = int(consumer.WorkerCount.Load())
 	}
 
@@ -347,52 +608,70 @@ func (h *TenantHandler) GetQueueStatus(c echo.Context) error {
 }
 
 // PublishMessage handles publishing a message to RabbitMQ for a tenant
+// @Summary Publish a message
+// @Description Publish a message to the tenant queue and wait for the broker confirm. The body is either the payload itself or an envelope: an object with "payload" whose top-level fields are all among "payload", "message_id", "type", "priority", "correlation_id" and "headers". A UUID message ID is generated when none is supplied.
+// @Tags tenants
+// @Accept json
+// @Produce json
+// @Param id path string true "Tenant ID"
+// @Param Idempotency-Key header string false "Replays the original response for retried requests"
+// @Param message body domain.PublishMessageRequest true "Message payload or envelope"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 429 {object} map[string]string
+// @Failure 503 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /tenants/{id}/publish [post]
 func (h *TenantHandler) PublishMessage(c echo.Context) error {
 	tenantID := c.Param("id")
 	if tenantID == "" {
 		return c.JSON(http.StatusBadRequest, map[string]string{"error": "tenant ID is required"})
 	}
 
-	// Parse request body
-	var message map[string]interface{}
-	if err := c.Bind(&message); err != nil {
-		return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid message format"})
+	// Refuse new messages for tenants that are being deleted or suspended
+	if err := h.tenantUseCase.EnsurePublishable(c.Request().Context(), tenantID); err != nil {
+		switch {
+		case errors.Is(err, usecase.ErrTenantNotFound):
+			return c.JSON(http.StatusNotFound, map[string]string{"error": err.Error()})
+		case errors.Is(err, usecase.ErrTenantDeleting), errors.Is(err, usecase.ErrTenantSuspended):
+			return c.JSON(http.StatusConflict, map[string]string{"error": err.Error()})
+		}
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
 	}
 
-	// Get channel from RabbitMQ
-	ch, err := h.tenantUseCase.GetChannel()
+	// Parse request body
+	body, err := io.ReadAll(c.Request().Body)
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "failed to get channel"})
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "failed to read request body"})
 	}
-	defer ch.Close()
 
-	// Convert message to JSON
-	messageBytes, err := json.Marshal(message)
+	msg, err := parsePublishing(body)
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "failed to marshal message"})
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
 	}
 
-	// Publish message to RabbitMQ
-	exchange := "" // Use default exchange
-	routingKey := fmt.Sprintf("tenant.%s", tenantID)
-
-	err = ch.Publish(
-		exchange,
-		routingKey,
-		false, // mandatory
-		false, // immediate
-		amqp.Publishing{
-			ContentType: "application/json",
-			Body:        messageBytes,
-		},
-	)
-
-	if err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "failed to publish message"})
+	// Publish message to RabbitMQ with publisher confirms and mandatory routing
+	if err := h.tenantUseCase.Publish(c.Request().Context(), tenantID, msg); err != nil {
+		return publishErrorResponse(c, err)
 	}
 
 	return c.JSON(http.StatusOK, map[string]interface{}{
-		"message":   "Message published successfully",
-		"tenant_id": tenantID,
+		"message":    "Message published successfully",
+		"tenant_id":  tenantID,
+		"message_id": msg.MessageId,
 	})
 }
+
+// publishErrorResponse maps publish errors to HTTP responses:
+// missing tenant queue -> 404, broker nack or missing confirm -> 503
+func publishErrorResponse(c echo.Context, err error) error {
+	switch {
+	case errors.Is(err, domain.ErrQueueNotFound):
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "tenant queue not found"})
+	case errors.Is(err, domain.ErrPublishNotConfirmed):
+		return c.JSON(http.StatusServiceUnavailable, map[string]string{"error": "message was not confirmed by the broker"})
+	}
+	return c.JSON(http.StatusInternalServerError, map[string]string{"error": "failed to publish message"})
+}
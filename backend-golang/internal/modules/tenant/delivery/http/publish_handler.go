@@ -0,0 +1,309 @@
+package http
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jatis/sample-stack-golang/internal/modules/tenant/domain"
+	"github.com/jatis/sample-stack-golang/internal/modules/tenant/usecase"
+	"github.com/jatis/sample-stack-golang/pkg/logger"
+	"github.com/labstack/echo/v4"
+	"github.com/streadway/amqp"
+)
+
+const (
+	// maxBatchSize adalah jumlah maksimal pesan dalam satu batch publish
+	maxBatchSize = 1000
+
+	// maxMessagePriority adalah prioritas AMQP tertinggi yang diterima
+	maxMessagePriority = 9
+
+	// maxMessageIDLength adalah panjang maksimal MessageId (AMQP shortstr)
+	maxMessageIDLength = 255
+)
+
+// BatchPublishResult represents the publish result of a single batch item
+type BatchPublishResult struct {
+	Index     int    `json:"index"`
+	MessageID string `json:"message_id"`
+	Status    string `json:"status"`
+	Error     string `json:"error,omitempty"`
+}
+
+// BatchPublishResponse represents the response of the batch publish endpoint
+type BatchPublishResponse struct {
+	TenantID  string               `json:"tenant_id"`
+	Total     int                  `json:"total"`
+	Published int                  `json:"published"`
+	Failed    int                  `json:"failed"`
+	Results   []BatchPublishResult `json:"results"`
+}
+
+// PublishBatch handles publishing multiple messages to RabbitMQ for a tenant
+// @Summary Publish a batch of messages
+// @Description Publish a JSON array of payloads, or NDJSON (Content-Type: application/x-ndjson), on one confirm-mode channel. Each item is either a payload or an envelope: an object with "payload" whose top-level fields are all among "payload", "message_id", "type", "priority", "correlation_id" and "headers". Message IDs must be unique within a batch. Returns 200 when every item is published and 207 when some items failed.
+// @Tags tenants
+// @Accept json
+// @Accept x-ndjson
+// @Produce json
+// @Param id path string true "Tenant ID"
+// @Param messages body []object true "Message payloads"
+// @Success 200 {object} BatchPublishResponse
+// @Success 207 {object} BatchPublishResponse
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 429 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /tenants/{id}/publish/batch [post]
+func (h *TenantHandler) PublishBatch(c echo.Context) error {
+	tenantID := c.Param("id")
+	if tenantID == "" {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "tenant ID is required"})
+	}
+
+	ctx := c.Request().Context()
+
+	// Refuse new messages for tenants that are being deleted or suspended
+	if err := h.tenantUseCase.EnsurePublishable(ctx, tenantID); err != nil {
+		switch {
+		case errors.Is(err, usecase.ErrTenantNotFound):
+			return c.JSON(http.StatusNotFound, map[string]string{"error": err.Error()})
+		case errors.Is(err, usecase.ErrTenantDeleting), errors.Is(err, usecase.ErrTenantSuspended):
+			return c.JSON(http.StatusConflict, map[string]string{"error": err.Error()})
+		}
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+
+	// Setiap pesan mendapat MessageId agar pesan yang dikembalikan broker dapat dicocokkan
+	msgs, err := parseBatchPublishings(c.Request())
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	if len(msgs) == 0 {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "batch must contain at least one message"})
+	}
+	if len(msgs) > maxBatchSize {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": fmt.Sprintf("batch must not contain more than %d messages", maxBatchSize)})
+	}
+
+	errs := h.tenantUseCase.PublishBatch(ctx, tenantID, msgs)
+
+	response := BatchPublishResponse{
+		TenantID: tenantID,
+		Total:    len(msgs),
+		Results:  make([]BatchPublishResult, len(msgs)),
+	}
+	for i, msg := range msgs {
+		result := BatchPublishResult{
+			Index:     i,
+			MessageID: msg.MessageId,
+			Status:    "published",
+		}
+		if errs[i] != nil {
+			result.Status = "failed"
+			result.Error = errs[i].Error()
+			response.Failed++
+		} else {
+			response.Published++
+		}
+		response.Results[i] = result
+	}
+
+	logger.Log.WithFields(map[string]interface{}{
+		"tenant_id": tenantID,
+		"total":     response.Total,
+		"published": response.Published,
+		"failed":    response.Failed,
+	}).Info("Published message batch")
+
+	if response.Failed > 0 {
+		return c.JSON(http.StatusMultiStatus, response)
+	}
+	return c.JSON(http.StatusOK, response)
+}
+
+// parseBatchPublishings membaca body batch berupa JSON array atau NDJSON lalu
+// mengubah setiap item menjadi amqp.Publishing dengan parsePublishing
+func parseBatchPublishings(req *http.Request) ([]amqp.Publishing, error) {
+	body, err := io.ReadAll(req.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read request body")
+	}
+
+	items, err := splitBatchItems(req.Header.Get(echo.HeaderContentType), body)
+	if err != nil {
+		return nil, err
+	}
+
+	// Pesan yang dikembalikan broker dicocokkan berdasarkan MessageId, sehingga
+	// MessageId harus unik di dalam satu batch
+	msgs := make([]amqp.Publishing, len(items))
+	seen := make(map[string]int, len(items))
+	for i, item := range items {
+		msg, err := parsePublishing(item)
+		if err != nil {
+			return nil, fmt.Errorf("item %d: %v", i, err)
+		}
+		if first, ok := seen[msg.MessageId]; ok {
+			return nil, fmt.Errorf("item %d: message_id %q duplicates item %d", i, msg.MessageId, first)
+		}
+		seen[msg.MessageId] = i
+		msgs[i] = msg
+	}
+
+	return msgs, nil
+}
+
+// splitBatchItems memecah body batch berupa JSON array atau NDJSON menjadi item mentah
+func splitBatchItems(contentType string, body []byte) ([]json.RawMessage, error) {
+	var items []json.RawMessage
+	if strings.HasPrefix(contentType, "application/x-ndjson") || strings.HasPrefix(contentType, "application/ndjson") {
+		scanner := bufio.NewScanner(bytes.NewReader(body))
+		scanner.Buffer(make([]byte, 64*1024), 10*1024*1024)
+		for scanner.Scan() {
+			line := bytes.TrimSpace(scanner.Bytes())
+			if len(line) == 0 {
+				continue
+			}
+			items = append(items, json.RawMessage(append([]byte(nil), line...)))
+		}
+		if err := scanner.Err(); err != nil {
+			return nil, fmt.Errorf("invalid NDJSON body: %v", err)
+		}
+	} else if err := json.Unmarshal(body, &items); err != nil {
+		return nil, fmt.Errorf("invalid message format, expected a JSON array or NDJSON")
+	}
+
+	return items, nil
+}
+
+// envelopeFields adalah field top-level domain.PublishMessageRequest
+var envelopeFields = map[string]bool{
+	"payload":        true,
+	"message_id":     true,
+	"type":           true,
+	"priority":       true,
+	"correlation_id": true,
+	"headers":        true,
+}
+
+// isEnvelope mengembalikan true jika body memiliki field "payload" dan seluruh field
+// top-level-nya adalah field envelope. Payload yang kebetulan memiliki field "payload"
+// di samping field lain tetap dikirim apa adanya.
+func isEnvelope(fields map[string]json.RawMessage) bool {
+	if _, ok := fields["payload"]; !ok {
+		return false
+	}
+	for key := range fields {
+		if !envelopeFields[key] {
+			return false
+		}
+	}
+	return true
+}
+
+// parsePublishing mengubah body publish menjadi amqp.Publishing. Body yang berupa
+// envelope (lihat isEnvelope) dibaca sebagai domain.PublishMessageRequest; body lain
+// dianggap payload. MessageId UUID dibuat jika tidak diberikan, dan pesan selalu
+// persistent dengan Timestamp.
+func parsePublishing(raw []byte) (amqp.Publishing, error) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &fields); err != nil || fields == nil {
+		return amqp.Publishing{}, fmt.Errorf("invalid message format")
+	}
+
+	var request domain.PublishMessageRequest
+	if isEnvelope(fields) {
+		if err := json.Unmarshal(raw, &request); err != nil {
+			return amqp.Publishing{}, fmt.Errorf("invalid message format: %v", err)
+		}
+	} else {
+		request.Payload = raw
+	}
+
+	// Payload harus berupa JSON object karena worker mendekodenya sebagai object
+	var payload map[string]interface{}
+	if err := json.Unmarshal(request.Payload, &payload); err != nil || payload == nil {
+		return amqp.Publishing{}, fmt.Errorf("payload must be a JSON object")
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return amqp.Publishing{}, fmt.Errorf("failed to marshal message")
+	}
+
+	if request.MessageID == "" {
+		request.MessageID = uuid.New().String()
+	} else if len(request.MessageID) > maxMessageIDLength {
+		return amqp.Publishing{}, fmt.Errorf("message_id must not be longer than %d characters", maxMessageIDLength)
+	}
+
+	if request.Priority > maxMessagePriority {
+		return amqp.Publishing{}, fmt.Errorf("priority must be between 0 and %d", maxMessagePriority)
+	}
+
+	headers, err := publishHeaders(request.Headers)
+	if err != nil {
+		return amqp.Publishing{}, err
+	}
+
+	return amqp.Publishing{
+		Headers:       headers,
+		ContentType:   "application/json",
+		DeliveryMode:  amqp.Persistent,
+		Priority:      request.Priority,
+		CorrelationId: request.CorrelationID,
+		MessageId:     request.MessageID,
+		Timestamp:     time.Now().UTC(),
+		Type:          request.Type,
+		Body:          body,
+	}, nil
+}
+
+// publishHeaders mengubah custom headers dari request menjadi amqp.Table.
+// Header berawalan "x-" dicadangkan untuk broker dan retry logic sehingga ditolak.
+func publishHeaders(headers map[string]interface{}) (amqp.Table, error) {
+	if len(headers) == 0 {
+		return nil, nil
+	}
+
+	table := amqp.Table{}
+	for key, value := range headers {
+		if strings.HasPrefix(strings.ToLower(key), "x-") {
+			return nil, fmt.Errorf("header %q is reserved", key)
+		}
+		table[key] = toAMQPValue(value)
+	}
+
+	if err := table.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid headers: %v", err)
+	}
+
+	return table, nil
+}
+
+// toAMQPValue mengubah nilai hasil decode JSON agar valid sebagai field amqp.Table
+func toAMQPValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		table := amqp.Table{}
+		for key, item := range v {
+			table[key] = toAMQPValue(item)
+		}
+		return table
+	case []interface{}:
+		for i, item := range v {
+			v[i] = toAMQPValue(item)
+		}
+		return v
+	}
+	return value
+}
@@ -0,0 +1,94 @@
+package http
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/streadway/amqp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParsePublishingRawPayload(t *testing.T) {
+	msg, err := parsePublishing([]byte(`{"order_id": 1, "metadata": {"source": "api"}}`))
+	require.NoError(t, err)
+
+	assert.JSONEq(t, `{"order_id": 1, "metadata": {"source": "api"}}`, string(msg.Body))
+	assert.Equal(t, "application/json", msg.ContentType)
+	assert.Equal(t, amqp.Persistent, msg.DeliveryMode)
+	assert.False(t, msg.Timestamp.IsZero())
+	assert.Empty(t, msg.Type)
+	assert.Nil(t, msg.Headers)
+
+	_, err = uuid.Parse(msg.MessageId)
+	assert.NoError(t, err, "message_id should be generated as a UUID")
+}
+
+func TestParsePublishingEnvelope(t *testing.T) {
+	msg, err := parsePublishing([]byte(`{
+		"payload": {"order_id": 1},
+		"message_id": "order-1",
+		"type": "order.created",
+		"priority": 5,
+		"correlation_id": "req-1",
+		"headers": {"source": "api", "attempt": 1, "trace": {"id": "abc"}}
+	}`))
+	require.NoError(t, err)
+
+	assert.JSONEq(t, `{"order_id": 1}`, string(msg.Body))
+	assert.Equal(t, "order-1", msg.MessageId)
+	assert.Equal(t, "order.created", msg.Type)
+	assert.Equal(t, uint8(5), msg.Priority)
+	assert.Equal(t, "req-1", msg.CorrelationId)
+	assert.Equal(t, amqp.Table{
+		"source":  "api",
+		"attempt": float64(1),
+		"trace":   amqp.Table{"id": "abc"},
+	}, msg.Headers)
+}
+
+func TestParsePublishingPayloadFieldInsideRawBody(t *testing.T) {
+	// Field selain field envelope berarti body adalah payload yang kebetulan
+	// memiliki field "payload", sehingga dikirim utuh
+	raw := `{"payload": {"card": "visa"}, "user_id": 7, "type": "checkout"}`
+
+	msg, err := parsePublishing([]byte(raw))
+	require.NoError(t, err)
+
+	assert.JSONEq(t, raw, string(msg.Body))
+	assert.Empty(t, msg.Type)
+}
+
+func TestParsePublishingRejectsInvalidBodies(t *testing.T) {
+	_, err := parsePublishing([]byte(`"hello"`))
+	assert.EqualError(t, err, "invalid message format")
+
+	_, err = parsePublishing([]byte(`{"payload": [1, 2, 3]}`))
+	assert.EqualError(t, err, "payload must be a JSON object")
+
+	_, err = parsePublishing([]byte(`{"payload": {}, "priority": 10}`))
+	assert.EqualError(t, err, "priority must be between 0 and 9")
+
+	_, err = parsePublishing([]byte(`{"payload": {}, "message_id": "` + strings.Repeat("a", maxMessageIDLength+1) + `"}`))
+	assert.EqualError(t, err, "message_id must not be longer than 255 characters")
+}
+
+func TestPublishHeaders(t *testing.T) {
+	table, err := publishHeaders(nil)
+	require.NoError(t, err)
+	assert.Nil(t, table)
+
+	table, err = publishHeaders(map[string]interface{}{
+		"source": "api",
+		"tags":   []interface{}{"a", map[string]interface{}{"b": true}},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, amqp.Table{"source": "api", "tags": []interface{}{"a", amqp.Table{"b": true}}}, table)
+
+	// Header berawalan x- dipakai retry logic, tanpa membedakan huruf besar/kecil
+	for _, key := range []string{"x-retry-count", "X-Last-Error"} {
+		_, err := publishHeaders(map[string]interface{}{key: "1"})
+		assert.EqualError(t, err, `header "`+key+`" is reserved`)
+	}
+}
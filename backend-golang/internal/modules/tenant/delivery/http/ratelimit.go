@@ -0,0 +1,80 @@
+package http
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/jatis/sample-stack-golang/internal/modules/tenant/domain"
+	appMiddleware "github.com/jatis/sample-stack-golang/pkg/middleware"
+	"github.com/jatis/sample-stack-golang/pkg/ratelimit"
+	"github.com/labstack/echo/v4"
+)
+
+// publishLimitCacheTTL adalah lama limit tenant di-cache sebelum dibaca ulang dari database
+const publishLimitCacheTTL = 10 * time.Second
+
+// cachedLimit adalah limit tenant beserta waktu kedaluwarsa cache-nya
+type cachedLimit struct {
+	limit     ratelimit.Limit
+	expiresAt time.Time
+}
+
+// PublishLimits mengembalikan LimitFunc yang membaca publish_rate dan publish_burst tenant.
+// Tenant dengan rate 0 memakai defaultLimit. Limit di-cache sebentar agar setiap publish
+// tidak membutuhkan query database; perubahan limit berlaku dalam publishLimitCacheTTL.
+func PublishLimits(tenantUseCase domain.TenantUseCase, defaultLimit ratelimit.Limit) appMiddleware.LimitFunc {
+	var (
+		mu    sync.Mutex
+		cache = make(map[string]cachedLimit)
+	)
+
+	return func(ctx context.Context, tenantID string) (ratelimit.Limit, error) {
+		now := time.Now()
+
+		mu.Lock()
+		cached, ok := cache[tenantID]
+		mu.Unlock()
+		if ok && now.Before(cached.expiresAt) {
+			return cached.limit, nil
+		}
+
+		tenant, err := tenantUseCase.GetByID(ctx, tenantID)
+		if err != nil {
+			return ratelimit.Limit{}, err
+		}
+
+		limit := ratelimit.Limit{Rate: tenant.PublishRate, Burst: tenant.PublishBurst}
+		if limit.Unlimited() {
+			limit = defaultLimit
+		}
+
+		mu.Lock()
+		cache[tenantID] = cachedLimit{limit: limit, expiresAt: now.Add(publishLimitCacheTTL)}
+		mu.Unlock()
+
+		return limit, nil
+	}
+}
+
+// PublishBatchCost menghitung jumlah pesan dalam body batch publish sehingga setiap
+// pesan mengambil satu token dari rate limit tenant
+func PublishBatchCost(c echo.Context) (int, error) {
+	req := c.Request()
+
+	body, err := io.ReadAll(req.Body)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read request body")
+	}
+	req.Body = io.NopCloser(bytes.NewReader(body))
+
+	items, err := splitBatchItems(req.Header.Get(echo.HeaderContentType), body)
+	if err != nil {
+		return 0, err
+	}
+
+	return len(items), nil
+}
@@ -2,10 +2,18 @@ package http
 
 import (
 	"github.com/labstack/echo/v4"
+
+	appMiddleware "github.com/jatis/sample-stack-golang/pkg/middleware"
 )
 
-// RegisterRoutes registers tenant routes
-func RegisterRoutes(e *echo.Echo, h *TenantHandler) {
+// RegisterRoutes registers tenant routes. The idempotency middleware is applied
+// to the publish endpoint so requests carrying an Idempotency-Key are replayed,
+// and publishLimit (Limiter and Limits) rate limits the publish endpoints per tenant.
+func RegisterRoutes(e *echo.Echo, h *TenantHandler, idempotency echo.MiddlewareFunc, publishLimit appMiddleware.RateLimitConfig) {
+	publishLimit.Param = "id"
+	batchLimit := publishLimit
+	batchLimit.Cost = PublishBatchCost
+
 	// Tenant routes
 	tenants := e.Group("/api/tenants")
 	tenants.POST("", h.CreateTenant)
@@ -13,16 +21,31 @@ func RegisterRoutes(e *echo.Echo, h *TenantHandler) {
 	tenants.GET("/consumers", h.GetTenantConsumers)
 	tenants.GET("/:id/consumers", h.GetTenantConsumers)
 	tenants.PUT("/:id/config/concurrency", h.UpdateConcurrency) // New endpoint for configuring concurrency
+	tenants.PUT("/:id/config/dedup", h.UpdateDedup)             // Endpoint for toggling consumer-side deduplication
+	tenants.PUT("/:id/config/publish-limit", h.UpdatePublishLimit) // Endpoint for configuring the publish rate limit
+	tenants.PUT("/:id/config/throughput", h.UpdateThroughput)       // Endpoint for configuring the consumption rate limit
 	
 	// RabbitMQ Publisher endpoints
-	tenants.POST("/:id/publish", h.PublishMessage)      // Endpoint for publishing messages to RabbitMQ
+	tenants.POST("/:id/publish", h.PublishMessage, idempotency, appMiddleware.RateLimit(publishLimit)) // Endpoint for publishing messages to RabbitMQ
+	tenants.POST("/:id/publish/batch", h.PublishBatch, appMiddleware.RateLimit(batchLimit))            // Endpoint for publishing a batch of messages on one channel
 	tenants.GET("/:id/queue-status", h.GetQueueStatus) // Endpoint for getting queue status
 	tenants.GET("/:id/dlq-status", h.GetDLQStatus)     // Endpoint for getting dead-letter queue status
+	tenants.GET("/:id/dlq/messages", h.GetDLQMessages) // Endpoint for browsing dead-lettered messages
+	tenants.POST("/:id/dlq/replay", h.ReplayDLQ)       // Endpoint for replaying dead-lettered messages
+	tenants.POST("/:id/dlq/messages/:message_id/replay", h.ReplayDLQMessage) // Endpoint for replaying a single dead-lettered message
+	tenants.DELETE("/:id/dlq", h.PurgeDLQ)             // Endpoint for purging the dead-letter queue
 	tenants.POST("/:id/activate", h.ActivateConsumer)  // Endpoint for activating consumer
+	tenants.POST("/:id/pause", h.Pause)                // Endpoint for pausing consumption, the queue keeps accumulating
+	tenants.POST("/:id/resume", h.Resume)              // Endpoint for resuming a paused consumer
+	tenants.PUT("/:id/status", h.UpdateStatus)         // Endpoint for changing the tenant status (active, paused, suspended)
 	
 	// tenants.POST("", h.Create)
 	tenants.GET("", h.List)
 	tenants.GET("/:id", h.GetByID)
 	tenants.PUT("/:id", h.Update)
 	tenants.DELETE("/:id", h.Delete)
+
+	// Admin routes
+	admin := e.Group("/api/admin")
+	admin.GET("/reconcile", h.GetReconcileReport) // Endpoint for the last tenant reconcile report
 }
\ No newline at end of file
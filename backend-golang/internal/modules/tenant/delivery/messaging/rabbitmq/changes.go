@@ -0,0 +1,285 @@
+package rabbitmq
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jatis/sample-stack-golang/internal/modules/tenant/domain"
+	"github.com/jatis/sample-stack-golang/pkg/logger"
+)
+
+const (
+	// tenantChangesChannel adalah channel NOTIFY yang dikirim trigger tenants_notify_change
+	tenantChangesChannel = "tenant_changes"
+
+	// changeListenRetryDelay adalah jeda sebelum listener perubahan tenant terhubung ulang
+	changeListenRetryDelay = 5 * time.Second
+)
+
+// tenantChange adalah payload notifikasi perubahan baris tenant
+type tenantChange struct {
+	TenantID string `json:"tenant_id"`
+	Op       string `json:"op"`
+}
+
+// listenChanges berlangganan notifikasi perubahan tabel tenants sehingga perubahan
+// konfigurasi yang dilayani replica lain (concurrency, prefetch, dedup, throughput,
+// pause/resume, hapus) diterapkan ke consumer di replica ini dalam hitungan detik.
+// Jika koneksi terputus, listener terhubung ulang dan menyinkronkan semua consumer lokal.
+func (m *TenantManager) listenChanges(ctx context.Context) {
+	for {
+		err := m.listenChangesOnce(ctx)
+
+		select {
+		case <-m.stopChan:
+			logger.Log.Info("Stopping tenant change listener")
+			return
+		case <-ctx.Done():
+			logger.Log.Info("Context cancelled, stopping tenant change listener")
+			return
+		default:
+		}
+
+		logger.Log.WithFields(map[string]interface{}{
+			"error":       err,
+			"retry_after": changeListenRetryDelay,
+		}).Warn("Tenant change listener disconnected, reconnecting")
+
+		select {
+		case <-m.stopChan:
+			return
+		case <-ctx.Done():
+			return
+		case <-time.After(changeListenRetryDelay):
+		}
+	}
+}
+
+// listenChangesOnce menjalankan LISTEN pada satu koneksi sampai koneksi tersebut gagal
+// atau manager dihentikan
+func (m *TenantManager) listenChangesOnce(ctx context.Context) error {
+	listenCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	// WaitForNotification hanya berhenti jika context-nya dibatalkan
+	go func() {
+		select {
+		case <-m.stopChan:
+			cancel()
+		case <-listenCtx.Done():
+		}
+	}()
+
+	poolConn, err := m.db.Acquire(listenCtx)
+	if err != nil {
+		return fmt.Errorf("failed to acquire connection: %w", err)
+	}
+	// Koneksi yang sudah LISTEN tidak dikembalikan ke pool
+	conn := poolConn.Hijack()
+	defer conn.Close(context.Background())
+
+	if _, err := conn.Exec(listenCtx, "LISTEN "+tenantChangesChannel); err != nil {
+		return fmt.Errorf("failed to listen for tenant changes: %w", err)
+	}
+	logger.Log.WithField("channel", tenantChangesChannel).Info("Listening for tenant changes")
+
+	// Perubahan yang terjadi selama listener terputus tidak dikirim ulang
+	m.syncLocalConsumers(listenCtx)
+
+	for {
+		notification, err := conn.WaitForNotification(listenCtx)
+		if err != nil {
+			return err
+		}
+
+		var change tenantChange
+		if err := json.Unmarshal([]byte(notification.Payload), &change); err != nil {
+			logger.Log.WithFields(map[string]interface{}{
+				"payload": notification.Payload,
+				"error":   err,
+			}).Warn("Invalid tenant change notification")
+			continue
+		}
+
+		m.applyTenantChange(listenCtx, change)
+	}
+}
+
+// applyTenantChange menerapkan satu perubahan tenant ke consumer lokal
+func (m *TenantManager) applyTenantChange(ctx context.Context, change tenantChange) {
+	var err error
+	if change.Op == "DELETE" {
+		err = m.stopDeletedConsumer(ctx, change.TenantID)
+	} else {
+		err = m.syncConsumer(ctx, change.TenantID)
+	}
+
+	if err != nil {
+		logger.Log.WithFields(map[string]interface{}{
+			"tenant_id": change.TenantID,
+			"op":        change.Op,
+			"error":     err,
+		}).Warn("Failed to apply tenant change")
+	}
+}
+
+// syncLocalConsumers menyinkronkan semua consumer di replica ini dengan database
+func (m *TenantManager) syncLocalConsumers(ctx context.Context) {
+	m.mu.RLock()
+	tenantIDs := make([]string, 0, len(m.consumers))
+	for tenantID := range m.consumers {
+		tenantIDs = append(tenantIDs, tenantID)
+	}
+	m.mu.RUnlock()
+
+	for _, tenantID := range tenantIDs {
+		if err := m.syncConsumer(ctx, tenantID); err != nil {
+			logger.Log.WithFields(map[string]interface{}{
+				"tenant_id": tenantID,
+				"error":     err,
+			}).Warn("Failed to sync tenant consumer")
+		}
+	}
+}
+
+// stopDeletedConsumer menghentikan consumer lokal tenant yang sudah dihapus. Queue-nya
+// sudah dihapus oleh replica yang melayani penghapusan.
+func (m *TenantManager) stopDeletedConsumer(ctx context.Context, tenantID string) error {
+	if m.GetConsumer(tenantID) == nil {
+		return nil
+	}
+
+	logger.Log.WithField("tenant_id", tenantID).Info("Tenant deleted on another replica, stopping local consumer")
+
+	return m.StopConsumer(ctx, tenantID)
+}
+
+// syncConsumer membaca konfigurasi tenant dari database dan menerapkannya ke consumer
+// lokal. Hanya nilai yang berbeda yang diubah, sehingga notifikasi untuk perubahan yang
+// sudah diterapkan replica ini sendiri tidak berdampak apa pun.
+func (m *TenantManager) syncConsumer(ctx context.Context, tenantID string) error {
+	// Consumer yang sedang dipulihkan atau diprovision membaca konfigurasi dari database sendiri
+	m.mu.RLock()
+	_, recovering := m.recovering[tenantID]
+	m.mu.RUnlock()
+	m.provisionMu.Lock()
+	_, provisioning := m.provisioning[tenantID]
+	m.provisionMu.Unlock()
+	if recovering || provisioning {
+		return nil
+	}
+
+	var workers, prefetch, throughputBurst int
+	var dedupEnabled bool
+	var throughputRate float64
+	var status string
+	err := m.db.QueryRow(ctx, `
+		SELECT workers, prefetch, dedup_enabled, throughput_rate, throughput_burst, status
+		FROM tenants WHERE id = $1`,
+		tenantID,
+	).Scan(&workers, &prefetch, &dedupEnabled, &throughputRate, &throughputBurst, &status)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return m.stopDeletedConsumer(ctx, tenantID)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get tenant config: %w", err)
+	}
+
+	m.mu.RLock()
+	c, exists := m.consumers[tenantID]
+	var current consumerSettings
+	if exists {
+		current = consumerSettings{
+			workers:  int(c.WorkerCount.Load()),
+			prefetch: int(c.Prefetch.Load()),
+			dedup:    c.Dedup.Load(),
+			paused:   c.Paused.Load(),
+		}
+	}
+	m.mu.RUnlock()
+
+	if status == domain.TenantStatusProvisioning || status == domain.TenantStatusFailed {
+		// Diurus oleh reconciler provisioning
+		return nil
+	}
+
+	if !exists {
+		// Tenant yang baru aktif, atau tenant deleting yang backlog-nya perlu di-drain,
+		// dijalankan oleh replica yang berhasil mengambil lease-nya
+		return m.StartConsumer(ctx, tenantID)
+	}
+
+	changes := diffConsumerSettings(consumerSettings{
+		workers:  workers,
+		prefetch: prefetch,
+		dedup:    dedupEnabled,
+		paused:   domain.IsConsumptionStopped(status),
+	}, current)
+
+	if changes.scale {
+		if err := m.ScaleWorkers(ctx, tenantID, workers); err != nil {
+			return fmt.Errorf("failed to scale consumer workers: %w", err)
+		}
+	}
+	if changes.prefetch {
+		if err := m.SetPrefetch(ctx, tenantID, prefetch); err != nil {
+			return fmt.Errorf("failed to update consumer prefetch: %w", err)
+		}
+	}
+	if changes.dedup {
+		if err := m.SetDedup(ctx, tenantID, dedupEnabled); err != nil {
+			return fmt.Errorf("failed to update consumer dedup: %w", err)
+		}
+	}
+	if err := m.SetThroughput(ctx, tenantID, throughputRate, throughputBurst); err != nil {
+		return fmt.Errorf("failed to update consumer throughput: %w", err)
+	}
+
+	switch {
+	case changes.pause:
+		if err := m.PauseConsumer(ctx, tenantID); err != nil {
+			return fmt.Errorf("failed to pause consumer: %w", err)
+		}
+	case changes.resume:
+		// Termasuk tenant deleting, yang tetap dikonsumsi agar backlog-nya habis
+		if err := m.ResumeConsumer(ctx, tenantID); err != nil {
+			return fmt.Errorf("failed to resume consumer: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// consumerSettings adalah konfigurasi consumer yang dapat diubah tanpa restart.
+// paused pada konfigurasi database berarti status tenant menghentikan konsumsi.
+type consumerSettings struct {
+	workers  int
+	prefetch int
+	dedup    bool
+	paused   bool
+}
+
+// consumerSettingChanges menandai setting consumer lokal yang perlu diubah
+type consumerSettingChanges struct {
+	scale    bool
+	prefetch bool
+	dedup    bool
+	pause    bool
+	resume   bool
+}
+
+// diffConsumerSettings membandingkan konfigurasi di database (want) dengan consumer
+// lokal (current). Prefetch <= 0 di database tidak diterapkan.
+func diffConsumerSettings(want, current consumerSettings) consumerSettingChanges {
+	return consumerSettingChanges{
+		scale:    want.workers != current.workers,
+		prefetch: want.prefetch > 0 && want.prefetch != current.prefetch,
+		dedup:    want.dedup != current.dedup,
+		pause:    want.paused && !current.paused,
+		resume:   !want.paused && current.paused,
+	}
+}
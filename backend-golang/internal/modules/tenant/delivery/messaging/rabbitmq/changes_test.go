@@ -0,0 +1,31 @@
+package rabbitmq
+
+import "testing"
+
+func TestDiffConsumerSettings(t *testing.T) {
+	current := consumerSettings{workers: 4, prefetch: 20, dedup: true}
+
+	if got := diffConsumerSettings(current, current); got != (consumerSettingChanges{}) {
+		t.Errorf("unchanged config: got %+v, want no changes", got)
+	}
+
+	got := diffConsumerSettings(consumerSettings{workers: 2, prefetch: 50, dedup: false, paused: true}, current)
+	want := consumerSettingChanges{scale: true, prefetch: true, dedup: true, pause: true}
+	if got != want {
+		t.Errorf("changed config: got %+v, want %+v", got, want)
+	}
+
+	// Prefetch yang tidak di-set di database tidak menimpa prefetch consumer
+	if got := diffConsumerSettings(consumerSettings{workers: 4, dedup: true}, current); got.prefetch {
+		t.Error("prefetch 0 should not be applied")
+	}
+
+	paused := current
+	paused.paused = true
+	if got := diffConsumerSettings(current, paused); !got.resume || got.pause {
+		t.Errorf("resume paused consumer: got %+v", got)
+	}
+	if got := diffConsumerSettings(paused, paused); got.pause || got.resume {
+		t.Errorf("already paused consumer: got %+v", got)
+	}
+}
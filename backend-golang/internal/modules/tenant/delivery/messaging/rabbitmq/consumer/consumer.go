@@ -3,23 +3,35 @@ package consumer
 import (
 	"context"
 	"fmt"
-	"time"
+	"math"
 
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/streadway/amqp"
 	"github.com/jatis/sample-stack-golang/internal/modules/tenant/domain"
 	"github.com/jatis/sample-stack-golang/pkg/logger"
 	"github.com/jatis/sample-stack-golang/pkg/rabbitmq"
+	"golang.org/x/time/rate"
 )
 
+// defaultPrefetch dipakai jika prefetch tenant tidak dapat dibaca dari database
+const defaultPrefetch = 10
+
+// StartWorkerFunc menjalankan satu worker; worker berhenti ketika stop channel miliknya
+// atau StopChannel consumer ditutup
+type StartWorkerFunc func(c *domain.TenantConsumer, workerID int, stop <-chan struct{})
+
+// ChannelFailureFunc dipanggil ketika channel consumer ditutup atau consumer di-cancel oleh broker
+type ChannelFailureFunc func(c *domain.TenantConsumer, reason string)
+
 // StartConsumer memulai consumer untuk tenant tertentu
 func StartConsumer(
 	ctx context.Context,
 	tenantID string,
-	rabbitConn *amqp.Connection,
+	rabbitConn *rabbitmq.Connection,
 	db *pgxpool.Pool,
 	addToWaitGroup func(),
-	startWorkerFunc func(*domain.TenantConsumer, int),
+	startWorkerFunc StartWorkerFunc,
+	onChannelFailure ChannelFailureFunc,
 ) (*domain.TenantConsumer, error) {
 	// Buat channel
 	ch, err := rabbitConn.Channel()
@@ -38,42 +50,48 @@ func StartConsumer(
 	}
 	
 	// Setup dead letter queue for tenant
-	routingKey, err := rabbitmq.SetupDeadLetterQueue(ch, tenantID, dlConfig)
-	if err != nil {
+	if _, err := rabbitmq.SetupDeadLetterQueue(ch, tenantID, dlConfig); err != nil {
 		ch.Close()
 		return nil, err
 	}
 	
+	// Setup retry queues with exponential backoff TTL for tenant
+	if err := rabbitmq.SetupRetryQueues(ch, tenantID, dlConfig); err != nil {
+		ch.Close()
+		return nil, err
+	}
+
 	// Declare main queue with dead-letter configuration
-	queueName := fmt.Sprintf("tenant.%s", tenantID)
-	args := rabbitmq.GetDeadLetterArgs(dlConfig.ExchangeName, routingKey, dlConfig.MessageTTL)
-	q, err := ch.QueueDeclare(
-		queueName,
-		true,  // durable
-		false, // delete when unused
-		false, // exclusive
-		false, // no-wait
-		args,  // arguments with dead-letter configuration
-	)
+	queueName, err := rabbitmq.DeclareTenantQueue(ch, tenantID, dlConfig)
 	if err != nil {
 		ch.Close()
-		return nil, fmt.Errorf("failed to declare queue: %v", err)
+		return nil, err
 	}
 
-	// Get tenant details from database to determine worker count
-	var workerCount int
-	if err := db.QueryRow(ctx, "SELECT workers FROM tenants WHERE id = $1", tenantID).Scan(&workerCount); err != nil {
+	// Get tenant details from database to determine worker count, prefetch, dedup, throughput and pause status
+	var workerCount, prefetch, throughputBurst int
+	var dedupEnabled bool
+	var throughputRate float64
+	var status string
+	query := "SELECT workers, prefetch, dedup_enabled, throughput_rate, throughput_burst, status FROM tenants WHERE id = $1"
+	if err := db.QueryRow(ctx, query, tenantID).Scan(&workerCount, &prefetch, &dedupEnabled, &throughputRate, &throughputBurst, &status); err != nil {
 		logger.Log.WithFields(map[string]interface{}{
 			"tenant_id": tenantID,
 			"error":     err,
-		}).Warn("Failed to get worker count from database, using default")
+		}).Warn("Failed to get worker count and prefetch from database, using default")
 		workerCount = 3 // Default worker count
+		prefetch = defaultPrefetch
+		dedupEnabled = false
+		throughputRate, throughputBurst = 0, 0
 	}
 
 	// Ensure worker count is at least 1
 	if workerCount < 1 {
 		workerCount = 1
 	}
+	if prefetch < 1 {
+		prefetch = defaultPrefetch
+	}
 
 	// Create buffered message channel for worker pool
 	messageChan := make(chan amqp.Delivery, workerCount*10) // Buffer size is 10x worker count
@@ -85,18 +103,58 @@ func StartConsumer(
 		ConsumerTag:   fmt.Sprintf("consumer.%s", tenantID),
 		Channel:       ch,
 		StopChannel:   make(chan struct{}),
-		IsActive:      true,
-		LastHeartbeat: time.Now(),
 		ErrorChannel:  make(chan error, 1),
 		MessageChan:   messageChan,
+		Throttle:      rate.NewLimiter(rate.Inf, 0),
 	}
+	consumer.IsActive.Store(true)
+	consumer.SetState(domain.ConsumerStateRunning)
+	consumer.Dedup.Store(dedupEnabled)
+	SetThroughput(consumer, throughputRate, throughputBurst)
+	consumer.Heartbeat()
+
+	// Batasi jumlah pesan unacked yang dikirim RabbitMQ ke consumer tenant
+	if err := SetPrefetch(consumer, prefetch); err != nil {
+		ch.Close()
+		return nil, err
+	}
+
+	// Pantau penutupan channel dan cancel dari broker (mis. queue dihapus)
+	closeNotify := ch.NotifyClose(make(chan *amqp.Error, 1))
+	cancelNotify := ch.NotifyCancel(make(chan string, 1))
+
+	// Tenant yang di-pause atau di-suspend tidak mulai consume; pesan tetap terkumpul di queue
+	if domain.IsConsumptionStopped(status) {
+		consumer.Paused.Store(true)
+		consumer.IsActive.Store(false)
+		consumer.SetState(domain.ConsumerStatePaused)
+	} else if err := startConsuming(consumer); err != nil {
+		ch.Close()
+		return nil, err
+	}
+
+	// Start channel watcher goroutine
+	go watchChannel(consumer, closeNotify, cancelNotify, onChannelFailure)
+
+	// Start worker pool
+	SpawnWorkers(consumer, workerCount, addToWaitGroup, startWorkerFunc)
 
-	// Initialize worker count atomic variable
-	consumer.WorkerCount.Store(int32(workerCount))
+	logger.Log.WithFields(map[string]interface{}{
+		"tenant_id":    tenantID,
+		"worker_count": workerCount,
+		"prefetch":     prefetch,
+		"dedup":        dedupEnabled,
+		"throughput":   throughputRate,
+		"paused":       consumer.Paused.Load(),
+	}).Info("Started consumer with worker pool")
+
+	return consumer, nil
+}
 
-	// Start consuming
-	msgs, err := ch.Consume(
-		q.Name,
+// startConsuming mendaftarkan consumer tag pada queue tenant dan menjalankan forwarding ke worker pool
+func startConsuming(consumer *domain.TenantConsumer) error {
+	msgs, err := consumer.Channel.Consume(
+		consumer.QueueName,
 		consumer.ConsumerTag,
 		false, // auto-ack
 		false, // exclusive
@@ -105,29 +163,174 @@ func StartConsumer(
 		nil,   // args
 	)
 	if err != nil {
-		ch.Close()
-		return nil, fmt.Errorf("failed to start consuming: %v", err)
+		return fmt.Errorf("failed to start consuming: %v", err)
 	}
 
 	// Start message forwarding goroutine
 	go forwardMessages(consumer, msgs)
 
-	// Start worker pool
-	for i := 0; i < workerCount; i++ {
-		workerID := i
+	return nil
+}
+
+// Pause membatalkan consumer tag sehingga broker berhenti mengirim pesan, tanpa menutup
+// channel, menghentikan worker, atau menghapus queue. Pesan yang sudah ada di buffer
+// worker dikembalikan ke queue oleh worker.
+func Pause(consumer *domain.TenantConsumer) error {
+	if consumer.Paused.Load() {
+		return nil
+	}
+
+	consumer.Paused.Store(true)
+	if err := consumer.Channel.Cancel(consumer.ConsumerTag, false); err != nil {
+		consumer.Paused.Store(false)
+		return fmt.Errorf("failed to cancel consumer: %v", err)
+	}
+
+	consumer.IsActive.Store(false)
+	consumer.SetState(domain.ConsumerStatePaused)
+
+	logger.Log.WithFields(map[string]interface{}{
+		"tenant_id": consumer.TenantID,
+	}).Info("Consumer paused")
+
+	return nil
+}
+
+// Resume mendaftarkan ulang consumer tag setelah Pause
+func Resume(consumer *domain.TenantConsumer) error {
+	if !consumer.Paused.Load() {
+		return nil
+	}
+
+	// Paused dilepas sebelum consumer tag didaftarkan ulang; jika tidak, worker
+	// mengembalikan pesan pertama yang dikirim broker ke queue karena consumer masih paused
+	consumer.Paused.Store(false)
+	if err := startConsuming(consumer); err != nil {
+		consumer.Paused.Store(true)
+		return err
+	}
+
+	consumer.IsActive.Store(true)
+	consumer.SetState(domain.ConsumerStateRunning)
+	consumer.Heartbeat()
+
+	logger.Log.WithFields(map[string]interface{}{
+		"tenant_id": consumer.TenantID,
+	}).Info("Consumer resumed")
+
+	return nil
+}
+
+// SpawnWorkers menambahkan n worker baru ke worker pool consumer
+func SpawnWorkers(consumer *domain.TenantConsumer, n int, addToWaitGroup func(), startWorkerFunc StartWorkerFunc) {
+	// Update jumlah worker sebelum worker berjalan agar metric worker count akurat
+	consumer.WorkerCount.Store(int32(len(consumer.WorkerStopChannels) + n))
+
+	for i := 0; i < n; i++ {
+		workerID := consumer.NextWorkerID()
+		stop := make(chan struct{})
+		consumer.WorkerStopChannels = append(consumer.WorkerStopChannels, stop)
+
 		// Add to waitgroup if provided
 		if addToWaitGroup != nil {
 			addToWaitGroup()
 		}
-		go startWorkerFunc(consumer, workerID)
+		go startWorkerFunc(consumer, workerID, stop)
+	}
+}
+
+// StopWorkers memberi sinyal berhenti ke n worker terakhir di worker pool consumer.
+// Worker menyelesaikan pesan yang sedang diproses sebelum keluar.
+func StopWorkers(consumer *domain.TenantConsumer, n int) {
+	if n > len(consumer.WorkerStopChannels) {
+		n = len(consumer.WorkerStopChannels)
+	}
+
+	remaining := len(consumer.WorkerStopChannels) - n
+	for _, stop := range consumer.WorkerStopChannels[remaining:] {
+		close(stop)
+	}
+	consumer.WorkerStopChannels = consumer.WorkerStopChannels[:remaining]
+
+	consumer.WorkerCount.Store(int32(remaining))
+}
+
+// ScaleWorkers mengubah jumlah worker consumer tanpa menghentikan channel maupun queue
+func ScaleWorkers(consumer *domain.TenantConsumer, workerCount int, addToWaitGroup func(), startWorkerFunc StartWorkerFunc) error {
+	if workerCount < 1 {
+		return fmt.Errorf("worker count must be at least 1")
+	}
+
+	current := len(consumer.WorkerStopChannels)
+	switch {
+	case workerCount > current:
+		SpawnWorkers(consumer, workerCount-current, addToWaitGroup, startWorkerFunc)
+	case workerCount < current:
+		StopWorkers(consumer, current-workerCount)
 	}
 
 	logger.Log.WithFields(map[string]interface{}{
-		"tenant_id":    tenantID,
-		"worker_count": workerCount,
-	}).Info("Started consumer with worker pool")
+		"tenant_id":        consumer.TenantID,
+		"previous_workers": current,
+		"worker_count":     workerCount,
+	}).Info("Scaled consumer worker pool")
 
-	return consumer, nil
+	return nil
+}
+
+// SetPrefetch menerapkan QoS prefetch pada channel consumer sehingga RabbitMQ tidak
+// mengirim lebih dari prefetch pesan unacked ke tenant ini. Dapat dipanggil saat consumer berjalan.
+func SetPrefetch(consumer *domain.TenantConsumer, prefetch int) error {
+	if prefetch < 1 {
+		return fmt.Errorf("prefetch must be at least 1")
+	}
+
+	if err := consumer.Channel.Qos(prefetch, 0, false); err != nil {
+		return fmt.Errorf("failed to set channel QoS: %v", err)
+	}
+	consumer.Prefetch.Store(int32(prefetch))
+
+	logger.Log.WithFields(map[string]interface{}{
+		"tenant_id": consumer.TenantID,
+		"prefetch":  prefetch,
+	}).Debug("Applied consumer prefetch")
+
+	return nil
+}
+
+// watchChannel menandai consumer tidak sehat ketika channel ditutup atau consumer
+// di-cancel oleh broker, lalu memberi tahu manager agar consumer dibuat ulang
+func watchChannel(consumer *domain.TenantConsumer, closeNotify <-chan *amqp.Error, cancelNotify <-chan string, onChannelFailure ChannelFailureFunc) {
+	var reason string
+
+	select {
+	case <-consumer.StopChannel:
+		return
+	case amqpErr, ok := <-closeNotify:
+		if !ok || amqpErr == nil {
+			reason = "channel closed"
+		} else {
+			reason = fmt.Sprintf("channel closed: %s (code %d)", amqpErr.Reason, amqpErr.Code)
+		}
+	case tag := <-cancelNotify:
+		reason = fmt.Sprintf("consumer %s cancelled by broker", tag)
+	}
+
+	// Penutupan yang disengaja (detach/stop) tidak perlu ditangani
+	select {
+	case <-consumer.StopChannel:
+		return
+	default:
+	}
+
+	logger.Log.WithFields(map[string]interface{}{
+		"tenant_id": consumer.TenantID,
+		"reason":    reason,
+	}).Error("Consumer channel failed, marking consumer unhealthy")
+
+	if onChannelFailure != nil {
+		onChannelFailure(consumer, reason)
+	}
 }
 
 // forwardMessages meneruskan pesan dari RabbitMQ ke message channel untuk diproses oleh worker pool
@@ -141,6 +344,14 @@ func forwardMessages(consumer *domain.TenantConsumer, msgs <-chan amqp.Delivery)
 			return
 		case msg, ok := <-msgs:
 			if !ok {
+				if consumer.Paused.Load() {
+					// Consumer tag dibatalkan oleh Pause
+					logger.Log.WithFields(map[string]interface{}{
+						"tenant_id": consumer.TenantID,
+					}).Info("Delivery channel closed after pause")
+					return
+				}
+
 				// Channel closed by RabbitMQ
 				logger.Log.WithFields(map[string]interface{}{
 					"tenant_id": consumer.TenantID,
@@ -148,6 +359,9 @@ func forwardMessages(consumer *domain.TenantConsumer, msgs <-chan amqp.Delivery)
 				return
 			}
 
+			// Pesan diterima dari broker, forwarding loop masih hidup
+			consumer.Heartbeat()
+
 			// Forward message to worker pool
 			select {
 			case consumer.MessageChan <- msg:
@@ -162,3 +376,26 @@ func forwardMessages(consumer *domain.TenantConsumer, msgs <-chan amqp.Delivery)
 		}
 	}
 }
+
+// SetThroughput membatasi jumlah pesan per detik yang diproses worker consumer.
+// rate <= 0 menghapus batas; burst <= 0 memakai rate (minimal 1). Dapat dipanggil
+// saat consumer berjalan, worker yang sedang menunggu langsung memakai limit baru.
+func SetThroughput(consumer *domain.TenantConsumer, limit float64, burst int) {
+	if limit <= 0 {
+		consumer.Throttle.SetLimit(rate.Inf)
+		consumer.Throttle.SetBurst(0)
+	} else {
+		if burst <= 0 {
+			burst = int(math.Max(1, math.Ceil(limit)))
+		}
+		// Burst diubah lebih dulu agar Wait tidak gagal karena burst 0 dengan limit terbatas
+		consumer.Throttle.SetBurst(burst)
+		consumer.Throttle.SetLimit(rate.Limit(limit))
+	}
+
+	logger.Log.WithFields(map[string]interface{}{
+		"tenant_id": consumer.TenantID,
+		"rate":      limit,
+		"burst":     burst,
+	}).Debug("Applied consumer throughput limit")
+}
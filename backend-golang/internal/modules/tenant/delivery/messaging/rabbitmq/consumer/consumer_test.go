@@ -0,0 +1,129 @@
+package consumer
+
+import (
+	"context"
+	"io"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/streadway/amqp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/jatis/sample-stack-golang/internal/modules/tenant/domain"
+	"github.com/jatis/sample-stack-golang/pkg/logger"
+	"golang.org/x/time/rate"
+)
+
+func init() {
+	logger.Log = logrus.New()
+	logger.Log.SetOutput(io.Discard)
+}
+
+// workerPool mencatat worker yang dijalankan dan worker yang sudah berhenti
+type workerPool struct {
+	mu      sync.Mutex
+	started []int
+	stopped []int
+	wg      sync.WaitGroup
+}
+
+func (p *workerPool) start(c *domain.TenantConsumer, workerID int, stop <-chan struct{}) {
+	defer p.wg.Done()
+
+	p.mu.Lock()
+	p.started = append(p.started, workerID)
+	p.mu.Unlock()
+
+	<-stop
+
+	p.mu.Lock()
+	p.stopped = append(p.stopped, workerID)
+	p.mu.Unlock()
+}
+
+func (p *workerPool) add() {
+	p.wg.Add(1)
+}
+
+func (p *workerPool) snapshot() (started, stopped []int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	started = append([]int(nil), p.started...)
+	stopped = append([]int(nil), p.stopped...)
+	sort.Ints(started)
+	sort.Ints(stopped)
+	return started, stopped
+}
+
+func TestScaleWorkers(t *testing.T) {
+	pool := &workerPool{}
+	c := &domain.TenantConsumer{TenantID: "t1"}
+
+	require.NoError(t, ScaleWorkers(c, 3, pool.add, pool.start))
+	assert.Equal(t, int32(3), c.WorkerCount.Load())
+	assert.Eventually(t, func() bool {
+		started, _ := pool.snapshot()
+		return len(started) == 3
+	}, time.Second, 10*time.Millisecond)
+
+	// Scale down menghentikan worker terbaru
+	require.NoError(t, ScaleWorkers(c, 1, pool.add, pool.start))
+	assert.Equal(t, int32(1), c.WorkerCount.Load())
+	assert.Len(t, c.WorkerStopChannels, 1)
+	assert.Eventually(t, func() bool {
+		_, stopped := pool.snapshot()
+		return assert.ObjectsAreEqual([]int{1, 2}, stopped)
+	}, time.Second, 10*time.Millisecond)
+
+	// Worker baru mendapat ID baru, bukan ID worker yang baru dihentikan
+	require.NoError(t, ScaleWorkers(c, 3, pool.add, pool.start))
+	assert.Equal(t, int32(3), c.WorkerCount.Load())
+	assert.Eventually(t, func() bool {
+		started, _ := pool.snapshot()
+		return assert.ObjectsAreEqual([]int{0, 1, 2, 3, 4}, started)
+	}, time.Second, 10*time.Millisecond)
+
+	assert.Error(t, ScaleWorkers(c, 0, pool.add, pool.start))
+	assert.Equal(t, int32(3), c.WorkerCount.Load())
+
+	StopWorkers(c, 10)
+	assert.Equal(t, int32(0), c.WorkerCount.Load())
+	assert.Empty(t, c.WorkerStopChannels)
+	pool.wg.Wait()
+}
+
+func TestSetThroughput(t *testing.T) {
+	c := &domain.TenantConsumer{TenantID: "t1", Throttle: rate.NewLimiter(rate.Inf, 0)}
+
+	SetThroughput(c, 2.5, 0)
+	assert.Equal(t, rate.Limit(2.5), c.Throttle.Limit())
+	assert.Equal(t, 3, c.Throttle.Burst(), "burst defaults to the rate rounded up")
+
+	SetThroughput(c, 10, 50)
+	assert.Equal(t, rate.Limit(10), c.Throttle.Limit())
+	assert.Equal(t, 50, c.Throttle.Burst())
+
+	SetThroughput(c, 0, 50)
+	assert.Equal(t, rate.Inf, c.Throttle.Limit())
+}
+
+func TestWaitThrottle(t *testing.T) {
+	c := &domain.TenantConsumer{TenantID: "t1", Throttle: rate.NewLimiter(rate.Inf, 0)}
+	SetThroughput(c, 1, 1)
+
+	acks := &deliveryAcks{}
+	assert.True(t, waitThrottle(context.Background(), c, 1, amqp.Delivery{Acknowledger: acks}))
+	assert.Equal(t, deliveryAcks{}, *acks)
+
+	// Worker yang dihentikan saat menunggu token mengembalikan pesannya ke queue
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	assert.False(t, waitThrottle(ctx, c, 1, amqp.Delivery{Acknowledger: acks}))
+	assert.True(t, acks.nacked)
+	assert.True(t, acks.requeued)
+}
@@ -0,0 +1,103 @@
+package consumer
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+const (
+	// DefaultDedupTTL adalah jendela deduplikasi jika TTL tidak dikonfigurasi
+	DefaultDedupTTL = 24 * time.Hour
+
+	// dedupStateProcessing menandai pesan yang sedang diproses oleh worker
+	dedupStateProcessing = "processing"
+
+	// dedupStateDone menandai pesan yang sudah berhasil diproses
+	dedupStateDone = "done"
+)
+
+// DedupResult adalah hasil pemeriksaan deduplikasi sebuah pesan
+type DedupResult int
+
+const (
+	// DedupNew berarti pesan belum pernah diproses dan boleh diproses
+	DedupNew DedupResult = iota
+	// DedupDuplicate berarti pesan sudah berhasil diproses sebelumnya
+	DedupDuplicate
+	// DedupInProgress berarti pesan dengan MessageId yang sama sedang diproses worker lain
+	DedupInProgress
+)
+
+// Deduplicator mencatat MessageId yang sudah diproses di Redis sehingga pesan yang
+// dikirim ulang (requeue atau consumer dibuat ulang) tidak diproses dua kali.
+//
+// Key disimpan dengan SETNX sebagai "processing" selama handler berjalan (TTL sebesar
+// handlerTimeout agar worker yang mati tidak mengunci pesan selamanya), lalu diubah
+// menjadi "done" dengan TTL jendela deduplikasi setelah pesan di-ack.
+type Deduplicator struct {
+	client *redis.Client
+	ttl    time.Duration
+}
+
+// NewDeduplicator membuat Deduplicator baru. ttl <= 0 memakai DefaultDedupTTL.
+func NewDeduplicator(client *redis.Client, ttl time.Duration) *Deduplicator {
+	if ttl <= 0 {
+		ttl = DefaultDedupTTL
+	}
+
+	return &Deduplicator{
+		client: client,
+		ttl:    ttl,
+	}
+}
+
+// Begin menandai pesan sebagai sedang diproses jika MessageId belum pernah terlihat
+func (d *Deduplicator) Begin(ctx context.Context, tenantID, messageID string) (DedupResult, error) {
+	key := dedupKey(tenantID, messageID)
+
+	acquired, err := d.client.SetNX(ctx, key, dedupStateProcessing, handlerTimeout).Result()
+	if err != nil {
+		return DedupNew, fmt.Errorf("failed to check message dedup key: %w", err)
+	}
+	if acquired {
+		return DedupNew, nil
+	}
+
+	state, err := d.client.Get(ctx, key).Result()
+	if err == redis.Nil {
+		// Key kedaluwarsa di antara SETNX dan GET; anggap masih diproses agar dicoba ulang
+		return DedupInProgress, nil
+	}
+	if err != nil {
+		return DedupNew, fmt.Errorf("failed to read message dedup key: %w", err)
+	}
+
+	if state == dedupStateDone {
+		return DedupDuplicate, nil
+	}
+	return DedupInProgress, nil
+}
+
+// Complete menandai pesan sudah berhasil diproses selama jendela deduplikasi
+func (d *Deduplicator) Complete(ctx context.Context, tenantID, messageID string) error {
+	if err := d.client.Set(ctx, dedupKey(tenantID, messageID), dedupStateDone, d.ttl).Err(); err != nil {
+		return fmt.Errorf("failed to mark message as processed: %w", err)
+	}
+	return nil
+}
+
+// Release menghapus penanda pesan yang gagal diproses sehingga retry-nya tetap diproses
+func (d *Deduplicator) Release(ctx context.Context, tenantID, messageID string) error {
+	if err := d.client.Del(ctx, dedupKey(tenantID, messageID)).Err(); err != nil {
+		return fmt.Errorf("failed to release message dedup key: %w", err)
+	}
+	return nil
+}
+
+// dedupKey mengembalikan key Redis untuk MessageId milik tenant
+func dedupKey(tenantID, messageID string) string {
+	return fmt.Sprintf("dedup:%s:%s", tenantID, messageID)
+}
@@ -0,0 +1,145 @@
+package consumer
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+	"github.com/streadway/amqp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/jatis/sample-stack-golang/internal/modules/tenant/domain"
+	"github.com/jatis/sample-stack-golang/pkg/rabbitmq"
+)
+
+func newTestDeduplicator(t *testing.T, ttl time.Duration) (*Deduplicator, *miniredis.Miniredis) {
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { client.Close() })
+
+	return NewDeduplicator(client, ttl), mr
+}
+
+func TestDeduplicatorLifecycle(t *testing.T) {
+	d, mr := newTestDeduplicator(t, time.Hour)
+	ctx := context.Background()
+
+	result, err := d.Begin(ctx, "t1", "m1")
+	require.NoError(t, err)
+	assert.Equal(t, DedupNew, result)
+	assert.Equal(t, handlerTimeout, mr.TTL("dedup:t1:m1"))
+
+	result, err = d.Begin(ctx, "t1", "m1")
+	require.NoError(t, err)
+	assert.Equal(t, DedupInProgress, result)
+
+	// MessageId yang sama milik tenant lain tidak terpengaruh
+	result, err = d.Begin(ctx, "t2", "m1")
+	require.NoError(t, err)
+	assert.Equal(t, DedupNew, result)
+
+	require.NoError(t, d.Complete(ctx, "t1", "m1"))
+	assert.Equal(t, time.Hour, mr.TTL("dedup:t1:m1"))
+
+	result, err = d.Begin(ctx, "t1", "m1")
+	require.NoError(t, err)
+	assert.Equal(t, DedupDuplicate, result)
+
+	// Setelah jendela deduplikasi habis, pesan dianggap baru lagi
+	mr.FastForward(time.Hour)
+	result, err = d.Begin(ctx, "t1", "m1")
+	require.NoError(t, err)
+	assert.Equal(t, DedupNew, result)
+}
+
+func TestDeduplicatorRelease(t *testing.T) {
+	d, mr := newTestDeduplicator(t, 0)
+	ctx := context.Background()
+
+	_, err := d.Begin(ctx, "t1", "m1")
+	require.NoError(t, err)
+	require.NoError(t, d.Release(ctx, "t1", "m1"))
+	assert.False(t, mr.Exists("dedup:t1:m1"))
+
+	// Retry setelah handler gagal diproses lagi
+	result, err := d.Begin(ctx, "t1", "m1")
+	require.NoError(t, err)
+	assert.Equal(t, DedupNew, result)
+}
+
+func TestDeduplicatorRedisUnavailable(t *testing.T) {
+	d, mr := newTestDeduplicator(t, 0)
+	mr.Close()
+
+	_, err := d.Begin(context.Background(), "t1", "m1")
+	assert.Error(t, err)
+}
+
+// deliveryAcks mencatat ack dan nack yang dilakukan worker pada sebuah delivery
+type deliveryAcks struct {
+	acked, nacked, requeued bool
+}
+
+func (a *deliveryAcks) Ack(tag uint64, multiple bool) error {
+	a.acked = true
+	return nil
+}
+
+func (a *deliveryAcks) Nack(tag uint64, multiple, requeue bool) error {
+	a.nacked, a.requeued = true, requeue
+	return nil
+}
+
+func (a *deliveryAcks) Reject(tag uint64, requeue bool) error {
+	a.requeued = requeue
+	return nil
+}
+
+func TestBeginDedup(t *testing.T) {
+	d, mr := newTestDeduplicator(t, time.Hour)
+	c := &domain.TenantConsumer{TenantID: "t1"}
+	c.Dedup.Store(true)
+	dlConfig := rabbitmq.NewDefaultDeadLetterConfig()
+
+	t.Run("new message gets a marker", func(t *testing.T) {
+		acks := &deliveryAcks{}
+		marker, skip := beginDedup(c, 1, amqp.Delivery{Acknowledger: acks, MessageId: "new"}, d, nil, dlConfig)
+		assert.Same(t, d, marker)
+		assert.False(t, skip)
+		assert.Equal(t, deliveryAcks{}, *acks)
+	})
+
+	t.Run("processed message is acknowledged without processing", func(t *testing.T) {
+		mr.Set("dedup:t1:done", dedupStateDone)
+		acks := &deliveryAcks{}
+		marker, skip := beginDedup(c, 1, amqp.Delivery{Acknowledger: acks, MessageId: "done"}, d, nil, dlConfig)
+		assert.Nil(t, marker)
+		assert.True(t, skip)
+		assert.True(t, acks.acked)
+	})
+
+	t.Run("in-progress message is requeued when it cannot be deferred", func(t *testing.T) {
+		mr.Set("dedup:t1:busy", dedupStateProcessing)
+		acks := &deliveryAcks{}
+		marker, skip := beginDedup(c, 1, amqp.Delivery{Acknowledger: acks, MessageId: "busy"}, d, nil, dlConfig)
+		assert.Nil(t, marker)
+		assert.True(t, skip)
+		assert.True(t, acks.nacked)
+		assert.True(t, acks.requeued)
+		assert.False(t, acks.acked)
+	})
+
+	t.Run("disabled dedup and messages without id skip the check", func(t *testing.T) {
+		marker, skip := beginDedup(c, 1, amqp.Delivery{Acknowledger: &deliveryAcks{}}, d, nil, dlConfig)
+		assert.Nil(t, marker)
+		assert.False(t, skip)
+
+		off := &domain.TenantConsumer{TenantID: "t1"}
+		marker, skip = beginDedup(off, 1, amqp.Delivery{Acknowledger: &deliveryAcks{}, MessageId: "busy"}, d, nil, dlConfig)
+		assert.Nil(t, marker)
+		assert.False(t, skip)
+	})
+}
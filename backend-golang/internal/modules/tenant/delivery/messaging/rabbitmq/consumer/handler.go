@@ -0,0 +1,167 @@
+package consumer
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/streadway/amqp"
+	messageDomain "github.com/jatis/sample-stack-golang/internal/modules/message/domain"
+	"github.com/jatis/sample-stack-golang/internal/modules/tenant/domain"
+	"github.com/jatis/sample-stack-golang/pkg/logger"
+)
+
+// HandlerRegistry menyimpan MessageHandler berdasarkan tenant ID atau header Type pesan.
+//
+// Urutan resolusi handler:
+//  1. handler khusus tenant
+//  2. handler untuk Type pesan
+//  3. fallback handler (jika tidak di-set, pesan langsung dikirim ke DLQ)
+type HandlerRegistry struct {
+	mu       sync.RWMutex
+	byTenant map[string]domain.MessageHandler
+	byType   map[string]domain.MessageHandler
+	fallback domain.MessageHandler
+}
+
+// NewHandlerRegistry membuat HandlerRegistry baru dengan fallback handler opsional
+func NewHandlerRegistry(fallback domain.MessageHandler) *HandlerRegistry {
+	return &HandlerRegistry{
+		byTenant: make(map[string]domain.MessageHandler),
+		byType:   make(map[string]domain.MessageHandler),
+		fallback: fallback,
+	}
+}
+
+// RegisterTenant mendaftarkan handler untuk semua pesan milik tenant tertentu
+func (r *HandlerRegistry) RegisterTenant(tenantID string, handler domain.MessageHandler) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.byTenant[tenantID] = handler
+}
+
+// RegisterType mendaftarkan handler untuk pesan dengan header Type tertentu
+func (r *HandlerRegistry) RegisterType(messageType string, handler domain.MessageHandler) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.byType[messageType] = handler
+}
+
+// SetFallback mengganti fallback handler. Nil berarti pesan tanpa handler dikirim ke DLQ.
+func (r *HandlerRegistry) SetFallback(handler domain.MessageHandler) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.fallback = handler
+}
+
+// Resolve mencari handler untuk pesan tenant dengan Type tertentu
+func (r *HandlerRegistry) Resolve(tenantID, messageType string) (domain.MessageHandler, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if handler, ok := r.byTenant[tenantID]; ok {
+		return handler, nil
+	}
+
+	if messageType != "" {
+		if handler, ok := r.byType[messageType]; ok {
+			return handler, nil
+		}
+	}
+
+	if r.fallback != nil {
+		return r.fallback, nil
+	}
+
+	return nil, fmt.Errorf("%w: tenant=%s type=%q", domain.ErrNoMessageHandler, tenantID, messageType)
+}
+
+// PersistHandler adalah handler default yang menyimpan pesan ke partisi tenant di tabel messages.
+// Pesan dengan metadata.force_error=true akan digagalkan untuk keperluan pengujian DLQ.
+type PersistHandler struct {
+	messageRepo messageDomain.MessageRepository
+}
+
+// NewPersistHandler membuat PersistHandler baru
+func NewPersistHandler(messageRepo messageDomain.MessageRepository) *PersistHandler {
+	return &PersistHandler{
+		messageRepo: messageRepo,
+	}
+}
+
+// Handle mendekode payload, memeriksa flag force_error, lalu menyimpan pesan
+func (h *PersistHandler) Handle(ctx context.Context, tenantID string, msg amqp.Delivery) error {
+	var payload map[string]interface{}
+	if err := json.Unmarshal(msg.Body, &payload); err != nil {
+		return fmt.Errorf("failed to decode message payload: %w", err)
+	}
+
+	// Periksa apakah ada flag metadata.force_error
+	if metadata, ok := payload["metadata"].(map[string]interface{}); ok {
+		if forceError, ok := metadata["force_error"].(bool); ok && forceError {
+			logger.Log.WithFields(map[string]interface{}{
+				"tenant_id":  tenantID,
+				"message_id": msg.MessageId,
+			}).Info("Force error flag detected, simulating processing error")
+			return fmt.Errorf("forced error for testing DLQ")
+		}
+	}
+
+	return h.persist(ctx, tenantID, msg)
+}
+
+// persist menyimpan pesan ke tabel messages menggunakan MessageId AMQP sebagai ID baris
+// sehingga redelivery pesan yang sama tidak menghasilkan baris duplikat
+func (h *PersistHandler) persist(ctx context.Context, tenantID string, msg amqp.Delivery) error {
+	if h.messageRepo == nil {
+		return fmt.Errorf("message repository not configured")
+	}
+
+	tenantUUID, err := uuid.Parse(tenantID)
+	if err != nil {
+		return fmt.Errorf("invalid tenant ID %q: %w", tenantID, err)
+	}
+
+	createdAt := msg.Timestamp
+	if createdAt.IsZero() {
+		createdAt = time.Now()
+	}
+
+	message := &messageDomain.Message{
+		ID:        messageIDFromDelivery(tenantID, msg),
+		TenantID:  tenantUUID,
+		Payload:   json.RawMessage(msg.Body),
+		CreatedAt: createdAt,
+		UpdatedAt: time.Now(),
+	}
+
+	if err := h.messageRepo.Create(ctx, message); err != nil {
+		return fmt.Errorf("failed to persist message: %w", err)
+	}
+
+	return nil
+}
+
+// messageIDFromDelivery menurunkan ID pesan dari MessageId AMQP.
+// MessageId yang bukan UUID dipetakan secara deterministik dengan UUIDv5,
+// sedangkan pesan tanpa MessageId mendapat UUID baru (tidak idempotent).
+func messageIDFromDelivery(tenantID string, msg amqp.Delivery) uuid.UUID {
+	if msg.MessageId == "" {
+		logger.Log.WithFields(map[string]interface{}{
+			"tenant_id": tenantID,
+		}).Warn("Message has no MessageId, generating a new ID; redeliveries will not be deduplicated")
+		return uuid.New()
+	}
+
+	if id, err := uuid.Parse(msg.MessageId); err == nil {
+		return id
+	}
+
+	return uuid.NewSHA1(uuid.NameSpaceOID, []byte(msg.MessageId))
+}
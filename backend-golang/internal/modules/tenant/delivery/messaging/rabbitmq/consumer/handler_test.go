@@ -0,0 +1,82 @@
+package consumer
+
+import (
+	"context"
+	"testing"
+
+	"github.com/streadway/amqp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/jatis/sample-stack-golang/internal/modules/tenant/domain"
+)
+
+// namedHandler adalah MessageHandler yang hanya dikenali dari namanya
+type namedHandler string
+
+func (h namedHandler) Handle(ctx context.Context, tenantID string, msg amqp.Delivery) error {
+	return nil
+}
+
+func TestHandlerRegistryResolve(t *testing.T) {
+	tests := []struct {
+		name        string
+		fallback    domain.MessageHandler
+		tenantID    string
+		messageType string
+		want        namedHandler
+		wantErr     bool
+	}{
+		{
+			name:        "tenant handler wins over type and fallback",
+			fallback:    namedHandler("fallback"),
+			tenantID:    "tenant-a",
+			messageType: "order.created",
+			want:        "tenant-a",
+		},
+		{
+			name:        "type handler wins over fallback",
+			fallback:    namedHandler("fallback"),
+			tenantID:    "tenant-b",
+			messageType: "order.created",
+			want:        "order.created",
+		},
+		{
+			name:        "fallback for unknown type",
+			fallback:    namedHandler("fallback"),
+			tenantID:    "tenant-b",
+			messageType: "order.cancelled",
+			want:        "fallback",
+		},
+		{
+			name:     "fallback for message without type",
+			fallback: namedHandler("fallback"),
+			tenantID: "tenant-b",
+			want:     "fallback",
+		},
+		{
+			name:        "no handler without fallback",
+			tenantID:    "tenant-b",
+			messageType: "order.cancelled",
+			wantErr:     true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			registry := NewHandlerRegistry(tt.fallback)
+			registry.RegisterTenant("tenant-a", namedHandler("tenant-a"))
+			registry.RegisterType("order.created", namedHandler("order.created"))
+
+			handler, err := registry.Resolve(tt.tenantID, tt.messageType)
+			if tt.wantErr {
+				assert.ErrorIs(t, err, domain.ErrNoMessageHandler)
+				assert.Nil(t, handler)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, handler)
+		})
+	}
+}
@@ -1,8 +1,7 @@
 package consumer
 
 import (
-	"encoding/json"
-	"fmt"
+	"context"
 	"time"
 
 	"github.com/streadway/amqp"
@@ -13,8 +12,14 @@ import (
 	"github.com/jatis/sample-stack-golang/pkg/rabbitmq"
 )
 
+// handlerTimeout adalah batas waktu pemrosesan satu pesan oleh MessageHandler
+const handlerTimeout = 30 * time.Second
+
 // StartWorker memulai worker untuk memproses pesan dari message channel
-func StartWorker(consumer *domain.TenantConsumer, workerID int, shutdownManager *graceful.ShutdownManager) {
+// Worker berhenti ketika stop (scale down) atau StopChannel consumer ditutup.
+// dedup boleh nil; jika di-set, tahap deduplikasi dijalankan untuk tenant dengan Dedup aktif.
+// publisher dipakai untuk mempublikasikan pesan gagal ke retry queue atau DLQ dengan confirm.
+func StartWorker(consumer *domain.TenantConsumer, workerID int, stop <-chan struct{}, handlers *HandlerRegistry, dedup *Deduplicator, publisher *rabbitmq.Publisher, shutdownManager *graceful.ShutdownManager) {
 	// Mark worker as done in waitgroup when finished if shutdown manager is available
 	if shutdownManager != nil {
 		defer shutdownManager.DoneTask()
@@ -24,10 +29,24 @@ func StartWorker(consumer *domain.TenantConsumer, workerID int, shutdownManager
 		"tenant_id": consumer.TenantID,
 		"worker_id": workerID,
 	}).Info("Starting worker")
-	
+
 	// Update worker count metric
 	metrics.UpdateWorkerCount(consumer.TenantID, float64(consumer.WorkerCount.Load()))
 
+	dlConfig := rabbitmq.NewDefaultDeadLetterConfig()
+
+	// throttleCtx dibatalkan saat worker diminta berhenti agar tidak tertahan di Throttle.Wait
+	throttleCtx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		select {
+		case <-consumer.StopChannel:
+		case <-stop:
+		case <-throttleCtx.Done():
+		}
+		cancel()
+	}()
+
 	for {
 		select {
 		case <-consumer.StopChannel:
@@ -36,6 +55,13 @@ func StartWorker(consumer *domain.TenantConsumer, workerID int, shutdownManager
 				"worker_id": workerID,
 			}).Info("Worker received stop signal")
 			return
+		case <-stop:
+			logger.Log.WithFields(map[string]interface{}{
+				"tenant_id": consumer.TenantID,
+				"worker_id": workerID,
+			}).Info("Worker stopped by scale down")
+			metrics.UpdateWorkerCount(consumer.TenantID, float64(consumer.WorkerCount.Load()))
+			return
 		case msg, ok := <-consumer.MessageChan:
 			if !ok {
 				// Channel closed, exit worker
@@ -46,163 +72,273 @@ func StartWorker(consumer *domain.TenantConsumer, workerID int, shutdownManager
 				return
 			}
 
-			// Process message
-			logger.Log.WithFields(map[string]interface{}{
-				"tenant_id":  consumer.TenantID,
-				"worker_id":  workerID,
-				"message_id": msg.MessageId,
-			}).Debug("Processing message")
-
-			// Mulai mengukur waktu pemrosesan pesan
-			startTime := time.Now()
-
-			// Simulasi pemrosesan dengan kemungkinan error
-			var processingError error
-			
-			// Saat ini hanya simulasi pemrosesan dengan delay
-			time.Sleep(100 * time.Millisecond)
-
-			// Periksa apakah pesan memiliki flag force_error
-			var payload map[string]interface{}
-
-			// Dekode body pesan
-			if err := json.Unmarshal(msg.Body, &payload); err != nil {
-				logger.Log.WithFields(map[string]interface{}{
-					"tenant_id":  consumer.TenantID,
-					"worker_id":  workerID,
-					"message_id": msg.MessageId,
-					"error":      err,
-				}).Error("Failed to decode message payload")
-				
-				// Jika gagal decode, reject pesan
-				if err := msg.Reject(false); err != nil {
+			// Pesan yang sudah di-buffer sebelum pause dikembalikan ke queue
+			if consumer.Paused.Load() {
+				if err := msg.Nack(false, true); err != nil {
 					logger.Log.WithFields(map[string]interface{}{
 						"tenant_id":  consumer.TenantID,
 						"worker_id":  workerID,
 						"message_id": msg.MessageId,
 						"error":      err,
-					}).Error("Failed to reject message after decode error")
+					}).Warn("Failed to requeue message of paused consumer")
 				}
+				continue
+			}
+
+			// Tunggu token throughput tenant sebelum memproses pesan
+			if !waitThrottle(throttleCtx, consumer, workerID, msg) {
 				return
 			}
 
-			// Log payload untuk debugging
+			// InFlight dipakai untuk mendeteksi apakah backlog tenant sudah habis (drain),
+			// sedangkan Begin/EndMessage dipakai health check untuk heartbeat dan deteksi stuck
+			consumer.InFlight.Add(1)
+			consumer.BeginMessage(workerID)
+			// Keputusan dedup diambil sekali per pesan agar penanda selalu diselesaikan atau
+			// dilepas meskipun dedup tenant diubah saat pesan diproses
+			if marker, skip := beginDedup(consumer, workerID, msg, dedup, publisher, dlConfig); !skip {
+				processDelivery(consumer, workerID, msg, handlers, marker, publisher, dlConfig)
+			}
+			consumer.EndMessage(workerID)
+			consumer.InFlight.Add(-1)
+		}
+	}
+}
+
+// waitThrottle menunggu sampai throughput tenant mengizinkan pesan berikutnya diproses.
+// Jika worker dihentikan selama menunggu, pesan dikembalikan ke queue dan false dikembalikan.
+func waitThrottle(ctx context.Context, consumer *domain.TenantConsumer, workerID int, msg amqp.Delivery) bool {
+	if consumer.Throttle == nil {
+		return true
+	}
+
+	if err := consumer.Throttle.Wait(ctx); err != nil {
+		logger.Log.WithFields(map[string]interface{}{
+			"tenant_id":  consumer.TenantID,
+			"worker_id":  workerID,
+			"message_id": msg.MessageId,
+			"error":      err,
+		}).Info("Worker stopped while throttled, requeueing message")
+
+		if err := msg.Nack(false, true); err != nil {
 			logger.Log.WithFields(map[string]interface{}{
 				"tenant_id":  consumer.TenantID,
 				"worker_id":  workerID,
 				"message_id": msg.MessageId,
-				"payload":    payload,
-			}).Debug("Decoded message payload")
+				"error":      err,
+			}).Warn("Failed to requeue throttled message")
+		}
+		return false
+	}
 
-			// Periksa apakah ada metadata.force_error
-			if metadata, ok := payload["metadata"].(map[string]interface{}); ok {
-				logger.Log.WithFields(map[string]interface{}{
-					"tenant_id":  consumer.TenantID,
-					"worker_id":  workerID,
-					"message_id": msg.MessageId,
-					"metadata":   metadata,
-				}).Debug("Checking message metadata for force_error flag")
-
-				// Periksa apakah ada flag force_error
-				if forceError, ok := metadata["force_error"].(bool); ok && forceError {
-					logger.Log.WithFields(map[string]interface{}{
-						"tenant_id":  consumer.TenantID,
-						"worker_id":  workerID,
-						"message_id": msg.MessageId,
-					}).Info("Force error flag detected, simulating processing error")
-					processingError = fmt.Errorf("forced error for testing DLQ")
-				}
-			} else {
-				logger.Log.WithFields(map[string]interface{}{
-					"tenant_id":  consumer.TenantID,
-					"worker_id":  workerID,
-					"message_id": msg.MessageId,
-					"payload":    payload,
-				}).Debug("No metadata field found in message payload")
-			}
+	return true
+}
 
-			// Simulasi error acak untuk testing (dalam produksi, ini akan diganti dengan error handling yang sebenarnya)
-			// Dalam implementasi nyata, ini akan diganti dengan logika bisnis yang sebenarnya
-			// dan error handling yang tepat
+// processDelivery memproses satu pesan: memilih handler, menjalankannya, lalu
+// melakukan ack atau menyerahkan pesan ke retry logic dan DLQ. dedup adalah nil jika
+// pesan tidak memiliki penanda deduplikasi.
+func processDelivery(consumer *domain.TenantConsumer, workerID int, msg amqp.Delivery, handlers *HandlerRegistry, dedup *Deduplicator, publisher *rabbitmq.Publisher, dlConfig *rabbitmq.DeadLetterConfig) {
+	// Process message
+	logger.Log.WithFields(map[string]interface{}{
+		"tenant_id":  consumer.TenantID,
+		"worker_id":  workerID,
+		"message_id": msg.MessageId,
+	}).Debug("Processing message")
 
-			// Jika terjadi error dalam pemrosesan
-			if processingError != nil {
-				logger.Log.WithFields(map[string]interface{}{
-					"tenant_id":  consumer.TenantID,
-					"worker_id":  workerID,
-					"message_id": msg.MessageId,
-					"error":      processingError,
-				}).Error("Message processing failed, handling with DLQ mechanism")
-
-				// Record processing time and failed message metric
-				processingTime := time.Since(startTime).Seconds()
-				metrics.RecordMessageProcessingTime(consumer.TenantID, processingTime)
-				metrics.RecordMessageProcessed(consumer.TenantID, "failed")
-
-				// Gunakan package rabbitmq untuk menangani error pemrosesan pesan
-				err := rabbitmq.HandleMessageProcessingError(
-					msg,
-					processingError,
-					consumer.QueueName,
-					consumer.TenantID,
-					workerID,
-					rabbitmq.DefaultMaxRetries,
-				)
-				
-				if err != nil {
-					logger.Log.WithFields(map[string]interface{}{
-						"tenant_id":  consumer.TenantID,
-						"worker_id":  workerID,
-						"message_id": msg.MessageId,
-						"error":      err,
-					}).Error("Failed to handle message processing error")
-				} else {
-					logger.Log.WithFields(map[string]interface{}{
-						"tenant_id":  consumer.TenantID,
-						"worker_id":  workerID,
-						"message_id": msg.MessageId,
-					}).Info("Successfully handled message processing error with DLQ mechanism")
-					
-					// Record retry metric
-					metrics.RecordMessageRetry(consumer.TenantID)
-				}
-			} else {
-				// Record processing time and successful message metric
-				processingTime := time.Since(startTime).Seconds()
-				metrics.RecordMessageProcessingTime(consumer.TenantID, processingTime)
-				metrics.RecordMessageProcessed(consumer.TenantID, "success")
-
-				// Jika pemrosesan berhasil, acknowledge message
-				if err := msg.Ack(false); err != nil {
-					logger.Log.WithFields(map[string]interface{}{
-						"tenant_id":  consumer.TenantID,
-						"worker_id":  workerID,
-						"message_id": msg.MessageId,
-						"error":      err,
-					}).Error("Failed to acknowledge message")
-				} else {
-					logger.Log.WithFields(map[string]interface{}{
-						"tenant_id":  consumer.TenantID,
-						"worker_id":  workerID,
-						"message_id": msg.MessageId,
-					}).Debug("Message processed successfully, acknowledging")
-				}
-			}
+	// Mulai mengukur waktu pemrosesan pesan
+	startTime := time.Now()
+
+	// Cari handler berdasarkan tenant ID atau header Type
+	handler, err := handlers.Resolve(consumer.TenantID, msg.Type)
+	if err != nil {
+		logger.Log.WithFields(map[string]interface{}{
+			"tenant_id":    consumer.TenantID,
+			"worker_id":    workerID,
+			"message_id":   msg.MessageId,
+			"message_type": msg.Type,
+			"error":        err,
+		}).Error("[DLQ] No handler for message, sending to dead-letter queue")
+
+		metrics.RecordMessageProcessed(consumer.TenantID, "failed")
+		releaseDedup(consumer, workerID, msg, dedup)
+
+		// Reject tanpa requeue akan mengirim pesan ke dead-letter queue
+		if err := msg.Reject(false); err != nil {
+			logger.Log.WithFields(map[string]interface{}{
+				"tenant_id":  consumer.TenantID,
+				"worker_id":  workerID,
+				"message_id": msg.MessageId,
+				"error":      err,
+			}).Error("Failed to reject message without handler")
+		} else {
+			metrics.RecordMessageDeadLettered(consumer.TenantID)
 		}
+		return
+	}
+
+	// Ack hanya dilakukan setelah handler selesai tanpa error
+	ctx, cancel := context.WithTimeout(context.Background(), handlerTimeout)
+	processingError := handler.Handle(ctx, consumer.TenantID, msg)
+	cancel()
+
+	// Jika terjadi error dalam pemrosesan
+	if processingError != nil {
+		logger.Log.WithFields(map[string]interface{}{
+			"tenant_id":  consumer.TenantID,
+			"worker_id":  workerID,
+			"message_id": msg.MessageId,
+			"error":      processingError,
+		}).Error("Message processing failed, handling with DLQ mechanism")
+
+		// Record processing time and failed message metric
+		processingTime := time.Since(startTime).Seconds()
+		metrics.RecordMessageProcessingTime(consumer.TenantID, processingTime)
+		metrics.RecordMessageProcessed(consumer.TenantID, "failed")
+
+		// Retry dipublikasikan ulang dengan MessageId yang sama, sehingga penanda dedup dilepas
+		releaseDedup(consumer, workerID, msg, dedup)
+
+		// Gunakan package rabbitmq untuk menjadwalkan retry atau mengirim ke DLQ
+		deadLettered, err := rabbitmq.HandleMessageProcessingError(
+			publisher,
+			msg,
+			processingError,
+			consumer.TenantID,
+			workerID,
+			dlConfig,
+		)
+
+		if err != nil {
+			logger.Log.WithFields(map[string]interface{}{
+				"tenant_id":  consumer.TenantID,
+				"worker_id":  workerID,
+				"message_id": msg.MessageId,
+				"error":      err,
+			}).Error("Failed to handle message processing error")
+		} else if deadLettered {
+			metrics.RecordMessageDeadLettered(consumer.TenantID)
+		} else {
+			metrics.RecordMessageRetry(consumer.TenantID)
+		}
+		return
+	}
+
+	// Record processing time and successful message metric
+	processingTime := time.Since(startTime).Seconds()
+	metrics.RecordMessageProcessingTime(consumer.TenantID, processingTime)
+	metrics.RecordMessageProcessed(consumer.TenantID, "success")
+
+	// Tandai selesai sebelum ack agar pengiriman ulang setelah ack gagal dianggap duplikat
+	completeDedup(consumer, workerID, msg, dedup)
+
+	// Jika pemrosesan berhasil, acknowledge message
+	if err := msg.Ack(false); err != nil {
+		logger.Log.WithFields(map[string]interface{}{
+			"tenant_id":  consumer.TenantID,
+			"worker_id":  workerID,
+			"message_id": msg.MessageId,
+			"error":      err,
+		}).Error("Failed to acknowledge message")
+	} else {
+		logger.Log.WithFields(map[string]interface{}{
+			"tenant_id":  consumer.TenantID,
+			"worker_id":  workerID,
+			"message_id": msg.MessageId,
+		}).Debug("Message processed successfully, acknowledging")
 	}
 }
 
-// ProcessMessage adalah fungsi untuk memproses pesan yang diterima
-// Ini adalah template yang dapat diimplementasikan sesuai kebutuhan bisnis
-func ProcessMessage(msg amqp.Delivery) error {
-	// Implementasi pemrosesan pesan sesuai kebutuhan bisnis
-	// Contoh:
-	// 1. Parse body pesan (msg.Body)
-	// 2. Validasi data
-	// 3. Simpan ke database atau proses sesuai kebutuhan bisnis
-	
-	// Simulasi pemrosesan
-	time.Sleep(100 * time.Millisecond)
-	
-	return nil
+// beginDedup menjalankan tahap deduplikasi sebelum handler. Pesan yang sudah
+// diproses di-ack tanpa diproses ulang, pesan yang sedang diproses worker lain
+// ditunda melalui retry queue pertama, dan jika Redis tidak tersedia pesan tetap diproses.
+// Mengembalikan deduplicator jika penanda "processing" berhasil dipasang (nil jika
+// tidak), dan skip = true jika pesan tidak perlu diproses.
+func beginDedup(consumer *domain.TenantConsumer, workerID int, msg amqp.Delivery, dedup *Deduplicator, publisher *rabbitmq.Publisher, dlConfig *rabbitmq.DeadLetterConfig) (marker *Deduplicator, skip bool) {
+	if dedup == nil || !consumer.Dedup.Load() || msg.MessageId == "" {
+		return nil, false
+	}
+
+	result, err := dedup.Begin(context.Background(), consumer.TenantID, msg.MessageId)
+	if err != nil {
+		logger.Log.WithFields(map[string]interface{}{
+			"tenant_id":  consumer.TenantID,
+			"worker_id":  workerID,
+			"message_id": msg.MessageId,
+			"error":      err,
+		}).Warn("Deduplication unavailable, processing message")
+		return nil, false
+	}
+
+	switch result {
+	case DedupDuplicate:
+		metrics.RecordMessageDuplicate(consumer.TenantID)
+		logger.Log.WithFields(map[string]interface{}{
+			"tenant_id":  consumer.TenantID,
+			"worker_id":  workerID,
+			"message_id": msg.MessageId,
+		}).Info("Duplicate message, acknowledging without processing")
+
+		if err := msg.Ack(false); err != nil {
+			logger.Log.WithFields(map[string]interface{}{
+				"tenant_id":  consumer.TenantID,
+				"worker_id":  workerID,
+				"message_id": msg.MessageId,
+				"error":      err,
+			}).Error("Failed to acknowledge duplicate message")
+		}
+		return nil, true
+	case DedupInProgress:
+		logger.Log.WithFields(map[string]interface{}{
+			"tenant_id":  consumer.TenantID,
+			"worker_id":  workerID,
+			"message_id": msg.MessageId,
+		}).Debug("Message is being processed by another worker, deferring")
+
+		// Nack dengan requeue langsung mengirim pesan kembali ke consumer dan membuat
+		// duplikat berputar selama worker lain masih memproses. Pesan ditunda melalui
+		// retry queue agar diperiksa lagi setelah delay, tanpa memblokir worker.
+		if err := rabbitmq.DeferMessage(publisher, msg, consumer.TenantID, dlConfig); err != nil {
+			logger.Log.WithFields(map[string]interface{}{
+				"tenant_id":  consumer.TenantID,
+				"worker_id":  workerID,
+				"message_id": msg.MessageId,
+				"error":      err,
+			}).Error("Failed to defer in-progress message")
+		}
+		return nil, true
+	}
+
+	return dedup, false
+}
+
+// completeDedup menandai pesan sudah diproses selama jendela deduplikasi
+func completeDedup(consumer *domain.TenantConsumer, workerID int, msg amqp.Delivery, dedup *Deduplicator) {
+	if dedup == nil {
+		return
+	}
+
+	if err := dedup.Complete(context.Background(), consumer.TenantID, msg.MessageId); err != nil {
+		logger.Log.WithFields(map[string]interface{}{
+			"tenant_id":  consumer.TenantID,
+			"worker_id":  workerID,
+			"message_id": msg.MessageId,
+			"error":      err,
+		}).Warn("Failed to record processed message for deduplication")
+	}
+}
+
+// releaseDedup melepas penanda pesan yang gagal diproses
+func releaseDedup(consumer *domain.TenantConsumer, workerID int, msg amqp.Delivery, dedup *Deduplicator) {
+	if dedup == nil {
+		return
+	}
+
+	if err := dedup.Release(context.Background(), consumer.TenantID, msg.MessageId); err != nil {
+		logger.Log.WithFields(map[string]interface{}{
+			"tenant_id":  consumer.TenantID,
+			"worker_id":  workerID,
+			"message_id": msg.MessageId,
+			"error":      err,
+		}).Warn("Failed to release message deduplication key")
+	}
 }
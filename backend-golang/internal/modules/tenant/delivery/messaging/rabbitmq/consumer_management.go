@@ -3,7 +3,6 @@ package rabbitmq
 import (
 	"context"
 	"fmt"
-	"time"
 
 	"github.com/jatis/sample-stack-golang/internal/modules/tenant/domain"
 	"github.com/jatis/sample-stack-golang/pkg/logger"
@@ -51,7 +50,7 @@ func (m *TenantManager) GetActiveConsumers() map[string]*domain.TenantConsumer {
 
 	activeConsumers := make(map[string]*domain.TenantConsumer)
 	for id, consumer := range m.consumers {
-		if consumer.IsActive {
+		if consumer.IsActive.Load() {
 			activeConsumers[id] = consumer
 		}
 	}
@@ -81,7 +80,7 @@ func (m *TenantManager) UpdateHeartbeat(tenantID string) {
 	defer m.mu.Unlock()
 
 	if consumer, exists := m.consumers[tenantID]; exists && consumer != nil {
-		consumer.LastHeartbeat = time.Now()
+		consumer.Heartbeat()
 	}
 }
 
@@ -123,8 +122,9 @@ func (m *TenantManager) logRabbitMQState(ctx context.Context, tenantID string) {
 			"tenant_id":      tenantID,
 			"queue_name":     consumer.QueueName,
 			"consumer_tag":   consumer.ConsumerTag,
-			"is_active":      consumer.IsActive,
-			"last_heartbeat": consumer.LastHeartbeat,
+			"is_active":      consumer.IsActive.Load(),
+			"last_heartbeat": consumer.LastHeartbeat(),
+			"health":         consumer.Health(),
 			"worker_count":   consumer.WorkerCount.Load(),
 		}).Info("Consumer state")
 
@@ -150,8 +150,9 @@ func (m *TenantManager) logRabbitMQState(ctx context.Context, tenantID string) {
 				"tenant_id":      id,
 				"queue_name":     consumer.QueueName,
 				"consumer_tag":   consumer.ConsumerTag,
-				"is_active":      consumer.IsActive,
-				"last_heartbeat": consumer.LastHeartbeat,
+				"is_active":      consumer.IsActive.Load(),
+				"last_heartbeat": consumer.LastHeartbeat(),
+				"health":         consumer.Health(),
 				"worker_count":   consumer.WorkerCount.Load(),
 			}).Info("Consumer state")
 		}
@@ -0,0 +1,360 @@
+package rabbitmq
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jatis/sample-stack-golang/internal/modules/tenant/domain"
+	"github.com/jatis/sample-stack-golang/pkg/logger"
+	"github.com/jatis/sample-stack-golang/pkg/rabbitmq"
+	"github.com/streadway/amqp"
+)
+
+const (
+	// drainInterval adalah interval reconciler memeriksa backlog tenant yang sedang di-drain
+	drainInterval = time.Second
+
+	// drainEmptyChecks adalah jumlah pemeriksaan kosong berturut-turut sebelum tenant dihapus,
+	// sehingga pesan yang sedang dikirim broker ke worker tidak terlewat
+	drainEmptyChecks = 2
+)
+
+// tenantDrain adalah drain-then-delete yang sedang berjalan (lihat tabel tenant_drains)
+type tenantDrain struct {
+	tenantID       string
+	previousStatus string
+	deadline       time.Time
+	emptyChecks    int
+}
+
+// expired mengembalikan true jika deadline drain sudah terlewati pada waktu now
+func (d *tenantDrain) expired(now time.Time) bool {
+	return !now.Before(d.deadline)
+}
+
+// drainLoop secara berkala melanjutkan drain-then-delete yang tercatat di database, sehingga
+// drain tetap berjalan setelah restart dan dapat diselesaikan oleh replica mana pun
+func (m *TenantManager) drainLoop(ctx context.Context) {
+	ticker := time.NewTicker(drainInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-m.stopChan:
+			logger.Log.Info("Stopping drain reconciler")
+			return
+		case <-ctx.Done():
+			logger.Log.Info("Context cancelled, stopping drain reconciler")
+			return
+		case <-ticker.C:
+			m.reconcileDrains(ctx)
+		}
+	}
+}
+
+// reconcileDrains menjalankan satu putaran untuk setiap drain yang masih berjalan
+func (m *TenantManager) reconcileDrains(ctx context.Context) {
+	rows, err := m.db.Query(ctx, `
+		SELECT tenant_id, previous_status, deadline, empty_checks
+		FROM tenant_drains
+		WHERE state = $1`,
+		domain.DrainStateDraining,
+	)
+	if err != nil {
+		logger.Log.WithField("error", err).Error("Failed to get running tenant drains")
+		return
+	}
+
+	var drains []*tenantDrain
+	for rows.Next() {
+		var d tenantDrain
+		if err := rows.Scan(&d.tenantID, &d.previousStatus, &d.deadline, &d.emptyChecks); err != nil {
+			logger.Log.WithField("error", err).Error("Failed to scan tenant drain")
+			continue
+		}
+		drains = append(drains, &d)
+	}
+	rows.Close()
+
+	for _, d := range drains {
+		if err := m.driveDrain(ctx, d); err != nil {
+			logger.Log.WithFields(map[string]interface{}{
+				"tenant_id": d.tenantID,
+				"error":     err,
+			}).Warn("Failed to drive tenant drain")
+		}
+	}
+}
+
+// driveDrain memastikan backlog tenant dikonsumsi, mencatat sisa backlog, dan menghapus
+// tenant setelah backlog kosong. Drain dibatalkan jika deadline terlewati.
+func (m *TenantManager) driveDrain(ctx context.Context, d *tenantDrain) error {
+	if d.expired(time.Now()) {
+		reason := fmt.Sprintf("backlog not drained before %s", d.deadline.Format(time.RFC3339))
+		return m.abortDrain(ctx, d, domain.DrainStateDraining, domain.DrainStateTimedOut, reason)
+	}
+
+	c := m.GetConsumer(d.tenantID)
+	if c != nil && c.State() == domain.ConsumerStateRecovering {
+		// Backlog diperiksa lagi setelah self-healing selesai
+		return nil
+	}
+
+	// Backlog tenant yang sebelumnya di-pause atau di-suspend hanya habis jika dikonsumsi lagi
+	if c != nil && c.Paused.Load() {
+		if err := m.ResumeConsumer(ctx, d.tenantID); err != nil {
+			return fmt.Errorf("failed to resume consumer for drain: %w", err)
+		}
+	}
+
+	backlog, consumers, err := m.drainBacklog(d.tenantID)
+	if err != nil {
+		return err
+	}
+
+	localPending := -1
+	if c != nil {
+		localPending = len(c.MessageChan) + int(c.InFlight.Load())
+	}
+
+	step := nextDrainStep(d, backlog, consumers, localPending)
+	switch step.action {
+	case drainWait:
+		return nil
+	case drainStartConsumer:
+		return m.StartConsumer(ctx, d.tenantID)
+	}
+
+	if _, err := m.db.Exec(ctx, `
+		UPDATE tenant_drains
+		SET pending_messages = $1, empty_checks = $2, updated_at = $3
+		WHERE tenant_id = $4 AND state = $5`,
+		step.pending, step.emptyChecks, time.Now(), d.tenantID, domain.DrainStateDraining,
+	); err != nil {
+		return fmt.Errorf("failed to record drain progress: %w", err)
+	}
+
+	if step.action != drainComplete {
+		return nil
+	}
+
+	return m.completeDrain(ctx, d)
+}
+
+// drainAction adalah langkah yang diambil sebuah replica pada satu putaran drain
+type drainAction int
+
+const (
+	// drainWait tidak mengubah drain pada putaran ini
+	drainWait drainAction = iota
+	// drainStartConsumer menjalankan consumer karena backlog belum habis dan tidak ada consumer
+	drainStartConsumer
+	// drainRecord mencatat sisa backlog dan jumlah pemeriksaan kosong
+	drainRecord
+	// drainComplete mencatat progress lalu menghapus queue dan tenant
+	drainComplete
+)
+
+// drainStep adalah hasil nextDrainStep
+type drainStep struct {
+	action      drainAction
+	pending     int
+	emptyChecks int
+}
+
+// nextDrainStep menentukan langkah drain dari backlog di broker, jumlah consumer main
+// queue di seluruh replica, dan pesan di buffer serta worker consumer lokal
+// (localPending < 0 jika replica ini tidak menjalankan consumer tenant).
+func nextDrainStep(d *tenantDrain, backlog, consumers, localPending int) drainStep {
+	if localPending < 0 {
+		switch {
+		case consumers > 0:
+			// Consumer tenant berjalan di replica lain; replica tersebut yang menyelesaikan drain
+			return drainStep{action: drainWait}
+		case backlog > 0:
+			// Tidak ada consumer sama sekali, mis. setelah restart; jika lease diaktifkan hanya
+			// satu replica yang berhasil menjalankannya
+			return drainStep{action: drainStartConsumer}
+		}
+		localPending = 0
+	}
+
+	step := drainStep{action: drainRecord, pending: backlog + localPending}
+
+	// Pesan yang sedang diproses consumer di replica lain tidak terlihat dari replica ini
+	if step.pending == 0 && consumers <= 1 {
+		step.emptyChecks = d.emptyChecks + 1
+	}
+	if step.emptyChecks >= drainEmptyChecks {
+		step.action = drainComplete
+	}
+
+	return step
+}
+
+// drainBacklog menghitung pesan ready di main queue dan retry queue tenant, serta jumlah
+// consumer di seluruh replica yang terpasang pada main queue. Queue yang sudah tidak ada
+// dihitung kosong.
+func (m *TenantManager) drainBacklog(tenantID string) (backlog, consumers int, err error) {
+	ch, err := m.rabbitConn.Channel()
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to open channel for queue inspection: %w", err)
+	}
+	// ch dapat diganti saat dibuka ulang dan bernilai nil jika pembukaan ulang gagal
+	defer func() {
+		if ch != nil {
+			ch.Close()
+		}
+	}()
+
+	// Pesan di retry queue akan kembali ke main queue setelah TTL habis
+	dlConfig := rabbitmq.NewDefaultDeadLetterConfig()
+	queueNames := []string{fmt.Sprintf("tenant.%s", tenantID)}
+	for attempt := int32(1); attempt <= dlConfig.MaxRetries; attempt++ {
+		queueNames = append(queueNames, dlConfig.RetryQueueName(tenantID, attempt))
+	}
+
+	for i, queueName := range queueNames {
+		queue, err := ch.QueueInspect(queueName)
+		if err != nil {
+			var amqpErr *amqp.Error
+			if !errors.As(err, &amqpErr) || amqpErr.Code != amqp.NotFound {
+				return 0, 0, fmt.Errorf("failed to inspect queue %s: %w", queueName, err)
+			}
+			// Channel ditutup oleh broker sehingga perlu dibuka ulang
+			ch.Close()
+			if ch, err = m.rabbitConn.Channel(); err != nil {
+				return 0, 0, fmt.Errorf("failed to reopen channel for queue inspection: %w", err)
+			}
+			continue
+		}
+
+		backlog += queue.Messages
+		if i == 0 {
+			consumers = queue.Consumers
+		}
+	}
+
+	return backlog, consumers, nil
+}
+
+// completeDrain menghapus queue dan tenant yang backlog-nya sudah kosong
+func (m *TenantManager) completeDrain(ctx context.Context, d *tenantDrain) error {
+	// Hanya satu replica yang menyelesaikan drain
+	now := time.Now()
+	result, err := m.db.Exec(ctx, `
+		UPDATE tenant_drains
+		SET state = $1, finished_at = $2, updated_at = $2
+		WHERE tenant_id = $3 AND state = $4`,
+		domain.DrainStateCompleted, now, d.tenantID, domain.DrainStateDraining,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to complete tenant drain: %w", err)
+	}
+	if result.RowsAffected() == 0 {
+		return nil
+	}
+
+	// Queue hanya dihapus jika masih kosong
+	if err := m.DecommissionTenant(ctx, d.tenantID, true); err != nil {
+		return m.abortDrain(ctx, d, domain.DrainStateCompleted, domain.DrainStateFailed, err.Error())
+	}
+
+	if err := m.deleteDrainedTenant(ctx, d.tenantID); err != nil {
+		// Queue sudah dihapus; drain dilanjutkan sehingga penghapusan tenant dicoba lagi
+		if _, resumeErr := m.db.Exec(ctx, `
+			UPDATE tenant_drains
+			SET state = $1, empty_checks = 0, finished_at = NULL, error = $2, updated_at = $3
+			WHERE tenant_id = $4 AND state = $5`,
+			domain.DrainStateDraining, err.Error(), time.Now(), d.tenantID, domain.DrainStateCompleted,
+		); resumeErr != nil {
+			logger.Log.WithFields(map[string]interface{}{
+				"tenant_id": d.tenantID,
+				"error":     resumeErr,
+			}).Error("Failed to resume tenant drain after failed deletion")
+		}
+		return fmt.Errorf("failed to delete drained tenant: %w", err)
+	}
+
+	logger.Log.WithField("tenant_id", d.tenantID).Info("Tenant drained and deleted")
+
+	return nil
+}
+
+// deleteDrainedTenant menghapus partition pesan dan baris tenant; baris tenant_drains
+// ikut terhapus. Tenant yang sudah tidak deleting tidak disentuh.
+func (m *TenantManager) deleteDrainedTenant(ctx context.Context, tenantID string) error {
+	tx, err := m.db.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx, "SELECT drop_messages_partition($1)", tenantID); err != nil {
+		return fmt.Errorf("failed to drop messages partition: %w", err)
+	}
+
+	result, err := tx.Exec(ctx, `DELETE FROM tenants WHERE id = $1 AND status = $2`, tenantID, domain.TenantStatusDeleting)
+	if err != nil {
+		return fmt.Errorf("failed to delete tenant: %w", err)
+	}
+	if result.RowsAffected() == 0 {
+		// Tenant sudah dihapus langsung; partition tidak di-drop dua kali
+		return nil
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+// abortDrain mencatat alasan drain berhenti dan mengembalikan status tenant sebelumnya.
+// Ini melewati tabel transisi, yang tidak memiliki transisi keluar dari deleting.
+func (m *TenantManager) abortDrain(ctx context.Context, d *tenantDrain, fromState, state, reason string) error {
+	tx, err := m.db.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	now := time.Now()
+	result, err := tx.Exec(ctx, `
+		UPDATE tenant_drains
+		SET state = $1, error = $2, finished_at = $3, updated_at = $3
+		WHERE tenant_id = $4 AND state = $5`,
+		state, reason, now, d.tenantID, fromState,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to abort tenant drain: %w", err)
+	}
+	if result.RowsAffected() == 0 {
+		// Drain sudah diselesaikan atau dibatalkan oleh replica lain
+		return nil
+	}
+
+	// Replica lain menerapkan status yang dipulihkan melalui notifikasi tenant_changes
+	if _, err := tx.Exec(ctx, `
+		UPDATE tenants
+		SET status = $1, status_reason = $2, updated_at = $3
+		WHERE id = $4 AND status = $5`,
+		d.previousStatus, "drain "+state+": "+reason, now, d.tenantID, domain.TenantStatusDeleting,
+	); err != nil {
+		return fmt.Errorf("failed to restore tenant status: %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	logger.Log.WithFields(map[string]interface{}{
+		"tenant_id": d.tenantID,
+		"state":     state,
+		"error":     reason,
+	}).Warn("Tenant drain aborted, tenant was not deleted")
+
+	return nil
+}
@@ -0,0 +1,88 @@
+package rabbitmq
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTenantDrainExpired(t *testing.T) {
+	deadline := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	d := &tenantDrain{tenantID: "t1", deadline: deadline}
+
+	if d.expired(deadline.Add(-time.Second)) {
+		t.Error("drain expired before its deadline")
+	}
+	if !d.expired(deadline) {
+		t.Error("drain not expired at its deadline")
+	}
+	if !d.expired(deadline.Add(time.Second)) {
+		t.Error("drain not expired after its deadline")
+	}
+}
+
+func TestNextDrainStep(t *testing.T) {
+	tests := []struct {
+		name         string
+		emptyChecks  int
+		backlog      int
+		consumers    int
+		localPending int
+		want         drainStep
+	}{
+		{
+			name:         "consumer runs on another replica",
+			backlog:      5,
+			consumers:    1,
+			localPending: -1,
+			want:         drainStep{action: drainWait},
+		},
+		{
+			name:         "backlog without any consumer starts one",
+			backlog:      5,
+			localPending: -1,
+			want:         drainStep{action: drainStartConsumer},
+		},
+		{
+			name:         "empty queue without any consumer counts as empty",
+			localPending: -1,
+			want:         drainStep{action: drainRecord, emptyChecks: 1},
+		},
+		{
+			name:         "local buffer and in-flight messages are pending",
+			emptyChecks:  1,
+			backlog:      2,
+			consumers:    1,
+			localPending: 3,
+			want:         drainStep{action: drainRecord, pending: 5},
+		},
+		{
+			name:         "first empty check",
+			consumers:    1,
+			localPending: 0,
+			want:         drainStep{action: drainRecord, emptyChecks: 1},
+		},
+		{
+			name:         "second empty check completes",
+			emptyChecks:  1,
+			consumers:    1,
+			localPending: 0,
+			want:         drainStep{action: drainComplete, emptyChecks: 2},
+		},
+		{
+			name:         "other replicas consuming never complete",
+			emptyChecks:  1,
+			consumers:    2,
+			localPending: 0,
+			want:         drainStep{action: drainRecord},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := &tenantDrain{tenantID: "t1", emptyChecks: tt.emptyChecks}
+			if got := nextDrainStep(d, tt.backlog, tt.consumers, tt.localPending); got != tt.want {
+				t.Errorf("nextDrainStep() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
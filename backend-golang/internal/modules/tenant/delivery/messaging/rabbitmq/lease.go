@@ -0,0 +1,159 @@
+package rabbitmq
+
+import (
+	"context"
+	"time"
+
+	"github.com/jatis/sample-stack-golang/pkg/logger"
+)
+
+// acquireOwnership mengembalikan true jika replica ini boleh menjalankan consumer tenant.
+// Jika Redis tidak tersedia, consumer baru tidak dijalankan agar tenant tidak dikonsumsi
+// oleh dua replica sekaligus; reconciler mencoba lagi pada putaran berikutnya.
+func (m *TenantManager) acquireOwnership(ctx context.Context, tenantID string) bool {
+	if m.leases == nil {
+		return true
+	}
+
+	owned, err := m.leases.Acquire(ctx, tenantID)
+	if err != nil {
+		logger.Log.WithFields(map[string]interface{}{
+			"tenant_id": tenantID,
+			"error":     err,
+		}).Warn("Failed to acquire tenant lease")
+		return false
+	}
+
+	if owned {
+		m.markLeaseRenewed(tenantID)
+	} else {
+		logger.Log.WithFields(map[string]interface{}{
+			"tenant_id": tenantID,
+			"replica":   m.leases.Owner(),
+		}).Debug("Tenant consumer is owned by another replica")
+	}
+
+	return owned
+}
+
+// releaseOwnership melepas lease tenant sehingga replica lain dapat langsung mengambil alih
+func (m *TenantManager) releaseOwnership(tenantID string) {
+	if m.leases == nil {
+		return
+	}
+
+	m.leaseMu.Lock()
+	delete(m.leaseRenewed, tenantID)
+	m.leaseMu.Unlock()
+
+	if err := m.leases.Release(context.Background(), tenantID); err != nil {
+		logger.Log.WithFields(map[string]interface{}{
+			"tenant_id": tenantID,
+			"error":     err,
+		}).Warn("Failed to release tenant lease")
+	}
+}
+
+// leaseRenewInterval adalah interval perpanjangan lease consumer lokal
+func (m *TenantManager) leaseRenewInterval() time.Duration {
+	return m.leases.TTL() / 3
+}
+
+// markLeaseRenewed mencatat bahwa lease tenant baru saja diambil atau diperpanjang
+func (m *TenantManager) markLeaseRenewed(tenantID string) {
+	m.leaseMu.Lock()
+	m.leaseRenewed[tenantID] = time.Now()
+	m.leaseMu.Unlock()
+}
+
+// leaseAtRisk mengembalikan true jika lease tenant bisa kedaluwarsa sebelum percobaan
+// perpanjangan berikutnya, sehingga replica lain dapat mengambil alih tenant
+func (m *TenantManager) leaseAtRisk(tenantID string) bool {
+	m.leaseMu.Lock()
+	renewed, ok := m.leaseRenewed[tenantID]
+	m.leaseMu.Unlock()
+
+	return !ok || time.Since(renewed) >= m.leases.TTL()-m.leaseRenewInterval()
+}
+
+// leaseLoop memperpanjang lease semua consumer lokal setiap sepertiga TTL. Consumer yang
+// lease-nya diambil replica lain dihentikan, begitu juga consumer yang lease-nya tidak
+// dapat diperpanjang (mis. Redis tidak terjangkau) sebelum lease tersebut kedaluwarsa.
+func (m *TenantManager) leaseLoop(ctx context.Context) {
+	if m.leases == nil {
+		return
+	}
+
+	ticker := time.NewTicker(m.leaseRenewInterval())
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-m.stopChan:
+			logger.Log.Info("Stopping tenant lease renewal")
+			return
+		case <-ctx.Done():
+			logger.Log.Info("Context cancelled, stopping tenant lease renewal")
+			return
+		case <-ticker.C:
+			m.renewLeases(ctx)
+		}
+	}
+}
+
+// renewLeases memperpanjang lease setiap consumer yang terdaftar di replica ini. Lease yang
+// sempat kedaluwarsa tetapi belum diambil replica lain diambil kembali. Jika perpanjangan
+// gagal dan lease bisa kedaluwarsa sebelum percobaan berikutnya, consumer lokal dihentikan
+// agar tenant tidak dikonsumsi dua replica sekaligus; reconciler memulainya lagi setelah
+// lease dapat diambil kembali.
+func (m *TenantManager) renewLeases(ctx context.Context) {
+	m.mu.RLock()
+	tenantIDs := make([]string, 0, len(m.consumers))
+	for tenantID := range m.consumers {
+		tenantIDs = append(tenantIDs, tenantID)
+	}
+	m.mu.RUnlock()
+
+	for _, tenantID := range tenantIDs {
+		renewed, err := m.leases.Acquire(ctx, tenantID)
+		if err != nil {
+			logger.Log.WithFields(map[string]interface{}{
+				"tenant_id": tenantID,
+				"error":     err,
+			}).Warn("Failed to renew tenant lease")
+
+			if !m.leaseAtRisk(tenantID) {
+				continue
+			}
+
+			logger.Log.WithFields(map[string]interface{}{
+				"tenant_id": tenantID,
+				"replica":   m.leases.Owner(),
+			}).Error("Tenant lease may expire before it can be renewed, stopping local consumer")
+
+			if err := m.StopConsumer(ctx, tenantID); err != nil {
+				logger.Log.WithFields(map[string]interface{}{
+					"tenant_id": tenantID,
+					"error":     err,
+				}).Error("Failed to stop consumer with unrenewable tenant lease")
+			}
+			continue
+		}
+		if renewed {
+			m.markLeaseRenewed(tenantID)
+			continue
+		}
+
+		logger.Log.WithFields(map[string]interface{}{
+			"tenant_id": tenantID,
+			"replica":   m.leases.Owner(),
+		}).Warn("Tenant lease lost, stopping local consumer")
+
+		if err := m.StopConsumer(ctx, tenantID); err != nil {
+			logger.Log.WithFields(map[string]interface{}{
+				"tenant_id": tenantID,
+				"error":     err,
+			}).Error("Failed to stop consumer after losing tenant lease")
+		}
+	}
+}
@@ -14,6 +14,16 @@ import (
 func (m *TenantManager) Start(ctx context.Context) error {
 	// Start health check goroutine
 	go m.healthCheck(ctx)
+	// Start reconciler untuk step provisioning yang pending atau gagal
+	go m.provisioningLoop(ctx)
+	// Start reconciler antara tabel tenants, consumer, dan queue di broker
+	go m.reconcileLoop(ctx)
+	// Start perpanjangan lease consumer tenant (hanya jika lease diaktifkan)
+	go m.leaseLoop(ctx)
+	// Start reconciler drain-then-delete yang tercatat di database
+	go m.drainLoop(ctx)
+	// Start listener perubahan tenant dari replica lain
+	go m.listenChanges(ctx)
 	return nil
 }
 
@@ -24,8 +34,7 @@ func (m *TenantManager) Stop(ctx context.Context) error {
 
 	// Stop semua consumers
 	m.mu.Lock()
-	defer m.mu.Unlock()
-
+	stopped := make([]string, 0, len(m.consumers))
 	for id := range m.consumers {
 		if err := m.stopConsumer(ctx, id); err != nil {
 			logger.Log.WithFields(map[string]interface{}{
@@ -33,6 +42,13 @@ func (m *TenantManager) Stop(ctx context.Context) error {
 				"error": err,
 			}).Error("Error stopping consumer")
 		}
+		stopped = append(stopped, id)
+	}
+	m.mu.Unlock()
+
+	// Lepas lease agar replica lain langsung mengambil alih tenant
+	for _, id := range stopped {
+		m.releaseOwnership(id)
 	}
 
 	return nil
@@ -40,12 +56,26 @@ func (m *TenantManager) Stop(ctx context.Context) error {
 
 // StartConsumer memulai consumer untuk tenant tertentu
 func (m *TenantManager) StartConsumer(ctx context.Context, tenantID string) error {
+	// Dengan lease, hanya replica pemilik yang menjalankan consumer tenant. Lease diambil
+	// sebelum m.mu agar Redis yang lambat tidak memblokir operasi consumer lain.
+	owned := m.acquireOwnership(ctx, tenantID)
+
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
 	// Get tenant details to determine worker count
 	// First, check if we already have a consumer for this tenant
 	oldConsumer, exists := m.consumers[tenantID]
+
+	if !owned {
+		if exists && oldConsumer != nil {
+			if err := m.stopConsumer(ctx, tenantID); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
 	if exists && oldConsumer != nil {
 		// Stop existing consumer first
 		if err := m.stopConsumer(ctx, tenantID); err != nil {
@@ -57,23 +87,14 @@ func (m *TenantManager) StartConsumer(ctx context.Context, tenantID string) erro
 	}
 
 	// Start consumer with worker pool
-	addToWaitGroup := func() {
-		if m.shutdownManager != nil {
-			m.shutdownManager.AddTask()
-		}
-	}
-
-	startWorkerFunc := func(c *domain.TenantConsumer, workerID int) {
-		consumer.StartWorker(c, workerID, m.shutdownManager)
-	}
-
 	newConsumer, err := consumer.StartConsumer(
 		ctx,
 		tenantID,
 		m.rabbitConn,
 		m.db,
-		addToWaitGroup,
-		startWorkerFunc,
+		m.addToWaitGroup,
+		m.startWorker,
+		m.onChannelFailure,
 	)
 
 	if err != nil {
@@ -82,19 +103,146 @@ func (m *TenantManager) StartConsumer(ctx context.Context, tenantID string) erro
 
 	// Simpan consumer
 	m.consumers[tenantID] = newConsumer
+	delete(m.recovering, tenantID)
+
+	return nil
+}
+
+// ScaleWorkers mengubah jumlah worker consumer tenant secara langsung tanpa
+// me-restart consumer, sehingga channel, consumer tag, dan queue tetap utuh
+func (m *TenantManager) ScaleWorkers(ctx context.Context, tenantID string, workers int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	c, err := m.getAndValidateConsumer(tenantID)
+	if err != nil {
+		return err
+	}
+	if c.State() == domain.ConsumerStateRecovering {
+		// Jumlah worker baru dibaca dari database saat consumer dibuat ulang
+		return nil
+	}
+
+	return consumer.ScaleWorkers(c, workers, m.addToWaitGroup, m.startWorker)
+}
+
+// SetPrefetch memperbarui QoS prefetch consumer tenant yang sedang berjalan
+func (m *TenantManager) SetPrefetch(ctx context.Context, tenantID string, prefetch int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	c, err := m.getAndValidateConsumer(tenantID)
+	if err != nil {
+		return err
+	}
+	if c.State() == domain.ConsumerStateRecovering {
+		// Prefetch baru dibaca dari database saat consumer dibuat ulang
+		return nil
+	}
+
+	return consumer.SetPrefetch(c, prefetch)
+}
+
+// SetDedup mengaktifkan atau menonaktifkan tahap deduplikasi consumer tenant yang sedang berjalan
+func (m *TenantManager) SetDedup(ctx context.Context, tenantID string, enabled bool) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	c, err := m.getAndValidateConsumer(tenantID)
+	if err != nil {
+		return err
+	}
+
+	// Consumer yang sedang dipulihkan membaca dedup_enabled dari database saat dibuat ulang
+	c.Dedup.Store(enabled)
+
+	return nil
+}
+
+// SetThroughput memperbarui batas pesan per detik consumer tenant yang sedang berjalan
+func (m *TenantManager) SetThroughput(ctx context.Context, tenantID string, rate float64, burst int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	c, err := m.getAndValidateConsumer(tenantID)
+	if err != nil {
+		return err
+	}
+	if c.State() == domain.ConsumerStateRecovering {
+		// Throughput baru dibaca dari database saat consumer dibuat ulang
+		return nil
+	}
+
+	consumer.SetThroughput(c, rate, burst)
 
 	return nil
 }
 
+// PauseConsumer menghentikan konsumsi pesan tenant tanpa menghapus consumer, queue, maupun DLQ
+func (m *TenantManager) PauseConsumer(ctx context.Context, tenantID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	c, err := m.getAndValidateConsumer(tenantID)
+	if err != nil {
+		return err
+	}
+	if c.State() == domain.ConsumerStateRecovering {
+		// Status paused dibaca dari database saat consumer dibuat ulang
+		return nil
+	}
+
+	return consumer.Pause(c)
+}
+
+// ResumeConsumer melanjutkan konsumsi pesan tenant yang di-pause
+func (m *TenantManager) ResumeConsumer(ctx context.Context, tenantID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	c, err := m.getAndValidateConsumer(tenantID)
+	if err != nil {
+		return err
+	}
+	if c.State() == domain.ConsumerStateRecovering {
+		// Status tenant dibaca dari database saat consumer dibuat ulang
+		return nil
+	}
+
+	return consumer.Resume(c)
+}
+
+// addToWaitGroup mendaftarkan worker baru ke shutdown manager jika tersedia
+func (m *TenantManager) addToWaitGroup() {
+	if m.shutdownManager != nil {
+		m.shutdownManager.AddTask()
+	}
+}
+
+// startWorker menjalankan worker dengan handler registry milik manager
+func (m *TenantManager) startWorker(c *domain.TenantConsumer, workerID int, stop <-chan struct{}) {
+	consumer.StartWorker(c, workerID, stop, m.handlers, m.dedup, m.publisher, m.shutdownManager)
+}
+
 // StopConsumer menghentikan consumer untuk tenant tertentu
 func (m *TenantManager) StopConsumer(ctx context.Context, tenantID string) error {
 	m.mu.Lock()
-	defer m.mu.Unlock()
+	// Batalkan pemulihan yang tertunda setelah reconnect
+	delete(m.recovering, tenantID)
+	err := m.stopConsumer(ctx, tenantID)
+	m.mu.Unlock()
+
+	if err != nil {
+		return err
+	}
+	// Lease dilepas di luar m.mu agar Redis yang lambat tidak memblokir operasi consumer lain
+	m.releaseOwnership(tenantID)
 
-	return m.stopConsumer(ctx, tenantID)
+	return nil
 }
 
-// stopConsumer menghentikan consumer dan membersihkan resources
+// stopConsumer melepas consumer dari queue (detach) tanpa menghapus queue,
+// DLQ, maupun retry queue, sehingga pesan yang menunggu tetap tersimpan
 func (m *TenantManager) stopConsumer(ctx context.Context, tenantID string) error {
 	consumer, err := m.getAndValidateConsumer(tenantID)
 	if err != nil {
@@ -106,38 +254,38 @@ func (m *TenantManager) stopConsumer(ctx context.Context, tenantID string) error
 		return err
 	}
 
-	// Delete queue
-	if err := m.deleteQueue(tenantID); err != nil {
-		logger.Log.WithFields(map[string]interface{}{
-			"tenant_id": tenantID,
-			"error":     err,
-		}).Warn("Failed to delete queue")
-	}
-
 	// Remove consumer from map
 	m.removeConsumerFromMap(tenantID)
 
 	return nil
 }
 
-// restartConsumer me-restart consumer
-func (m *TenantManager) restartConsumer(ctx context.Context, tenantID string) error {
-	// Stop consumer
-	if err := m.StopConsumer(ctx, tenantID); err != nil {
-		return fmt.Errorf("failed to stop consumer: %w", err)
-	}
+// DecommissionTenant menghentikan consumer (jika ada) lalu menghapus infrastruktur
+// RabbitMQ milik tenant: main queue, DLQ, dan retry queue. Hanya dipanggil saat tenant
+// dihapus. Jika ifEmpty bernilai true, main queue hanya dihapus bila sudah kosong.
+func (m *TenantManager) DecommissionTenant(ctx context.Context, tenantID string, ifEmpty bool) error {
+	// Lease dilepas setelah m.mu dilepas agar Redis yang lambat tidak memblokir operasi consumer lain
+	defer m.releaseOwnership(tenantID)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
 
-	// Start consumer
-	if err := m.StartConsumer(ctx, tenantID); err != nil {
-		return fmt.Errorf("failed to start consumer: %w", err)
+	delete(m.recovering, tenantID)
+	if _, exists := m.consumers[tenantID]; exists {
+		if err := m.stopConsumer(ctx, tenantID); err != nil {
+			logger.Log.WithFields(map[string]interface{}{
+				"tenant_id": tenantID,
+				"error":     err,
+			}).Warn("Failed to stop consumer before decommissioning tenant")
+		}
 	}
 
-	return nil
+	return m.deleteQueue(tenantID, ifEmpty)
 }
 
 // healthCheck melakukan health check secara periodik
 func (m *TenantManager) healthCheck(ctx context.Context) {
-	ticker := time.NewTicker(30 * time.Second)
+	ticker := time.NewTicker(m.health.HealthCheckInterval)
 	defer ticker.Stop()
 
 	for {
@@ -149,32 +297,57 @@ func (m *TenantManager) healthCheck(ctx context.Context) {
 			logger.Log.Info("Context cancelled, stopping health check")
 			return
 		case <-ticker.C:
-			m.mu.RLock()
-			for id, consumer := range m.consumers {
-				// Skip if consumer is not active
-				if !consumer.IsActive {
-					continue
-				}
-
-				// Check if consumer is still active
-				if time.Since(consumer.LastHeartbeat) > 60*time.Second {
-					logger.Log.WithFields(map[string]interface{}{
-						"tenant_id": id,
-						"last_heartbeat": consumer.LastHeartbeat,
-					}).Warn("Consumer heartbeat timeout, restarting")
-
-					// Restart consumer
-					go func(tenantID string) {
-						if err := m.restartConsumer(ctx, tenantID); err != nil {
-							logger.Log.WithFields(map[string]interface{}{
-								"tenant_id": tenantID,
-								"error":     err,
-							}).Error("Failed to restart consumer")
-						}
-					}(id)
-				}
+			m.checkConsumersHealth()
+		}
+	}
+}
+
+// checkConsumersHealth mengklasifikasikan setiap consumer aktif:
+//   - stuck: ada pesan yang diproses lebih lama dari StuckThreshold, consumer dibuat ulang
+//   - idle: tidak ada heartbeat lebih lama dari IdleThreshold (biasanya queue kosong), tidak di-restart
+//   - healthy: selain itu
+func (m *TenantManager) checkConsumersHealth() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	for id, c := range m.consumers {
+		// Consumer unhealthy/recovering ditangani oleh mekanisme recovery
+		if !c.IsActive.Load() {
+			continue
+		}
+
+		if started, busy := c.OldestMessageStart(); busy {
+			if elapsed := now.Sub(started); elapsed > m.health.StuckThreshold {
+				c.SetHealth(domain.ConsumerHealthStuck)
+				logger.Log.WithFields(map[string]interface{}{
+					"tenant_id":       id,
+					"busy_since":      started,
+					"stuck_threshold": m.health.StuckThreshold.String(),
+				}).Warn("Consumer stuck processing a message, recreating consumer")
+
+				// Channel lama ditutup sehingga pesan unacked dikirim ulang ke consumer baru.
+				// Worker yang stuck keluar setelah handler-nya selesai.
+				m.detachForRecovery(id, c, fmt.Sprintf("message in progress for %s", elapsed.Round(time.Second)))
+				go m.retryRecovery(id)
+				continue
 			}
-			m.mu.RUnlock()
+
+			c.SetHealth(domain.ConsumerHealthHealthy)
+			continue
 		}
+
+		if now.Sub(c.LastHeartbeat()) > m.health.IdleThreshold {
+			if c.Health() != domain.ConsumerHealthIdle {
+				logger.Log.WithFields(map[string]interface{}{
+					"tenant_id":      id,
+					"last_heartbeat": c.LastHeartbeat(),
+				}).Debug("Consumer is idle")
+			}
+			c.SetHealth(domain.ConsumerHealthIdle)
+			continue
+		}
+
+		c.SetHealth(domain.ConsumerHealthHealthy)
 	}
 }
@@ -1,32 +1,106 @@
 package rabbitmq
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"sync"
+	"time"
 
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/streadway/amqp"
+	"github.com/jatis/sample-stack-golang/internal/config"
+	"github.com/jatis/sample-stack-golang/internal/modules/tenant/delivery/messaging/rabbitmq/consumer"
 	"github.com/jatis/sample-stack-golang/internal/modules/tenant/domain"
 	"github.com/jatis/sample-stack-golang/pkg/graceful"
+	"github.com/jatis/sample-stack-golang/pkg/lease"
+	"github.com/jatis/sample-stack-golang/pkg/rabbitmq"
+)
+
+const (
+	// defaultHealthCheckInterval dipakai jika consumer.health_check_interval tidak di-set
+	defaultHealthCheckInterval = 30 * time.Second
+
+	// defaultIdleThreshold dipakai jika consumer.idle_threshold tidak di-set
+	defaultIdleThreshold = 5 * time.Minute
+
+	// defaultStuckThreshold dipakai jika consumer.stuck_threshold tidak di-set
+	defaultStuckThreshold = 2 * time.Minute
+
+	// defaultReconcileInterval dipakai jika consumer.reconcile_interval tidak di-set
+	defaultReconcileInterval = time.Minute
 )
 
 // TenantManager mengimplementasikan domain.TenantManager untuk RabbitMQ
 type TenantManager struct {
-	rabbitConn      *amqp.Connection
+	rabbitConn      *rabbitmq.Connection
 	consumers       map[string]*domain.TenantConsumer
+	// recovering berisi tenant yang consumer-nya gagal dimulai ulang setelah reconnect
+	recovering      map[string]struct{}
 	mu              sync.RWMutex
+	// provisioning berisi tenant yang step provisioning-nya sedang dijalankan
+	provisioning    map[string]struct{}
+	provisionMu     sync.Mutex
+	// lastReport adalah hasil reconcile terakhir
+	lastReport      *domain.ReconcileReport
+	reportMu        sync.RWMutex
 	stopChan        chan struct{}
 	db              *pgxpool.Pool
+	handlers        *consumer.HandlerRegistry
+	dedup           *consumer.Deduplicator
+	// leases menentukan replica pemilik consumer tenant; nil berarti semua tenant dimiliki
+	leases          *lease.Locker
+	// leaseRenewed berisi waktu lease tenant terakhir berhasil diambil atau diperpanjang
+	leaseRenewed    map[string]time.Time
+	leaseMu         sync.Mutex
+	publisher       *rabbitmq.Publisher
+	health          config.ConsumerConfig
 	shutdownManager *graceful.ShutdownManager
 }
 
 // NewTenantManager membuat instance baru dari TenantManager
-func NewTenantManager(rabbitConn *amqp.Connection, db *pgxpool.Pool) domain.TenantManager {
-	return &TenantManager{
-		rabbitConn: rabbitConn,
-		consumers:  make(map[string]*domain.TenantConsumer),
-		stopChan:   make(chan struct{}),
-		db:         db,
+// Handler registry dipakai oleh setiap worker untuk memilih MessageHandler per pesan.
+// Setiap kali koneksi RabbitMQ pulih, semua consumer yang terdaftar dimulai ulang.
+// dedup boleh nil; jika di-set, worker tenant dengan dedup aktif menjalankan tahap deduplikasi.
+// leases boleh nil; jika di-set, consumer tenant hanya dijalankan oleh replica pemegang lease
+// tenant tersebut, dan tanpa lease setiap replica menjalankan consumer untuk semua tenant.
+// healthConfig berisi threshold health check; nilai kosong memakai default.
+func NewTenantManager(rabbitConn *rabbitmq.Connection, db *pgxpool.Pool, handlers *consumer.HandlerRegistry, dedup *consumer.Deduplicator, leases *lease.Locker, healthConfig config.ConsumerConfig) domain.TenantManager {
+	if handlers == nil {
+		// Tanpa registry, semua pesan langsung dikirim ke DLQ
+		handlers = consumer.NewHandlerRegistry(nil)
+	}
+
+	if healthConfig.HealthCheckInterval <= 0 {
+		healthConfig.HealthCheckInterval = defaultHealthCheckInterval
 	}
+	if healthConfig.IdleThreshold <= 0 {
+		healthConfig.IdleThreshold = defaultIdleThreshold
+	}
+	if healthConfig.StuckThreshold <= 0 {
+		healthConfig.StuckThreshold = defaultStuckThreshold
+	}
+	if healthConfig.ReconcileInterval <= 0 {
+		healthConfig.ReconcileInterval = defaultReconcileInterval
+	}
+
+	m := &TenantManager{
+		rabbitConn:   rabbitConn,
+		consumers:    make(map[string]*domain.TenantConsumer),
+		recovering:   make(map[string]struct{}),
+		provisioning: make(map[string]struct{}),
+		stopChan:     make(chan struct{}),
+		db:           db,
+		handlers:     handlers,
+		dedup:        dedup,
+		leases:       leases,
+		leaseRenewed: make(map[string]time.Time),
+		publisher:    rabbitmq.NewPublisher(rabbitConn, rabbitmq.DefaultPublisherPoolSize, rabbitmq.DefaultConfirmTimeout),
+		health:       healthConfig,
+	}
+	rabbitConn.OnReconnect(m.recoverConsumers)
+
+	return m
 }
 
 // SetShutdownManager sets the shutdown manager for graceful shutdown
@@ -38,3 +112,39 @@ func (m *TenantManager) SetShutdownManager(sm *graceful.ShutdownManager) {
 func (m *TenantManager) GetChannel() (*amqp.Channel, error) {
 	return m.rabbitConn.Channel()
 }
+
+// Publish mempublikasikan pesan ke queue tenant melalui pool channel confirm-mode.
+// ErrQueueNotFound dikembalikan jika queue tenant tidak ada, ErrPublishNotConfirmed
+// jika broker menolak atau tidak mengkonfirmasi pesan.
+func (m *TenantManager) Publish(ctx context.Context, tenantID string, msg amqp.Publishing) error {
+	routingKey := fmt.Sprintf("tenant.%s", tenantID)
+
+	return publishError(m.publisher.Publish(ctx, "", routingKey, msg))
+}
+
+// PublishBatch mempublikasikan beberapa pesan ke queue tenant pada satu channel
+// confirm-mode dan mengembalikan error per pesan
+func (m *TenantManager) PublishBatch(ctx context.Context, tenantID string, msgs []amqp.Publishing) []error {
+	routingKey := fmt.Sprintf("tenant.%s", tenantID)
+
+	errs := m.publisher.PublishBatch(ctx, "", routingKey, msgs)
+	for i, err := range errs {
+		errs[i] = publishError(err)
+	}
+
+	return errs
+}
+
+// publishError memetakan error publisher ke error domain
+func publishError(err error) error {
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, rabbitmq.ErrUnroutable):
+		return fmt.Errorf("%w: %v", domain.ErrQueueNotFound, err)
+	case errors.Is(err, rabbitmq.ErrNacked), errors.Is(err, rabbitmq.ErrConfirmTimeout):
+		return fmt.Errorf("%w: %v", domain.ErrPublishNotConfirmed, err)
+	}
+
+	return err
+}
@@ -0,0 +1,264 @@
+package rabbitmq
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jatis/sample-stack-golang/internal/modules/tenant/domain"
+	"github.com/jatis/sample-stack-golang/pkg/logger"
+	"github.com/jatis/sample-stack-golang/pkg/rabbitmq"
+)
+
+const (
+	// provisioningInterval adalah interval reconciler mencari step provisioning yang pending
+	provisioningInterval = 10 * time.Second
+
+	// provisioningBaseBackoff adalah jeda sebelum percobaan ulang pertama step yang gagal
+	provisioningBaseBackoff = 5 * time.Second
+
+	// provisioningMaxBackoff membatasi jeda antar percobaan ulang
+	provisioningMaxBackoff = 5 * time.Minute
+)
+
+// Provision menjalankan step provisioning tenant yang masih pending secara berurutan
+// (lihat tabel tenant_provisioning). Jika sebuah step gagal, percobaan dan error-nya
+// dicatat, status tenant menjadi failed dengan alasan kegagalan, dan reconciler
+// mencoba ulang dengan backoff. Setelah semua step selesai tenant menjadi active.
+func (m *TenantManager) Provision(ctx context.Context, tenantID string) error {
+	// Jangan jalankan provisioning tenant yang sama dua kali bersamaan
+	m.provisionMu.Lock()
+	if _, running := m.provisioning[tenantID]; running {
+		m.provisionMu.Unlock()
+		return nil
+	}
+	m.provisioning[tenantID] = struct{}{}
+	m.provisionMu.Unlock()
+
+	defer func() {
+		m.provisionMu.Lock()
+		delete(m.provisioning, tenantID)
+		m.provisionMu.Unlock()
+	}()
+
+	steps, err := m.pendingProvisioningSteps(ctx, tenantID)
+	if err != nil {
+		return err
+	}
+
+	for _, step := range steps {
+		if err := m.runProvisioningStep(ctx, tenantID, step.Step); err != nil {
+			m.recordProvisioningFailure(tenantID, step, err)
+			return fmt.Errorf("provisioning step %s failed: %w", step.Step, err)
+		}
+
+		if _, err := m.db.Exec(ctx, `
+			UPDATE tenant_provisioning
+			SET state = $1, last_error = '', updated_at = $2
+			WHERE tenant_id = $3 AND step = $4`,
+			domain.ProvisioningStateDone, time.Now(), tenantID, step.Step,
+		); err != nil {
+			return fmt.Errorf("failed to mark provisioning step %s as done: %w", step.Step, err)
+		}
+	}
+
+	// Hanya tenant yang masih provisioning atau failed yang diaktifkan, agar status yang
+	// diubah selama provisioning (mis. deleting) tidak ditimpa
+	if _, err := m.db.Exec(ctx, `
+		UPDATE tenants
+		SET status = $1, status_reason = '', updated_at = $2
+		WHERE id = $3 AND status IN ($4, $5)`,
+		domain.TenantStatusActive, time.Now(), tenantID,
+		domain.TenantStatusProvisioning, domain.TenantStatusFailed,
+	); err != nil {
+		return fmt.Errorf("failed to activate tenant: %w", err)
+	}
+
+	if len(steps) > 0 {
+		logger.Log.WithField("tenant_id", tenantID).Info("Tenant provisioned")
+	}
+
+	return nil
+}
+
+// pendingProvisioningSteps mengambil step yang belum selesai dalam urutan eksekusi
+func (m *TenantManager) pendingProvisioningSteps(ctx context.Context, tenantID string) ([]*domain.ProvisioningStep, error) {
+	rows, err := m.db.Query(ctx, `
+		SELECT step, state, attempts, last_error
+		FROM tenant_provisioning
+		WHERE tenant_id = $1 AND state = $2
+		ORDER BY position`,
+		tenantID, domain.ProvisioningStatePending,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get provisioning steps: %w", err)
+	}
+	defer rows.Close()
+
+	var steps []*domain.ProvisioningStep
+	for rows.Next() {
+		var step domain.ProvisioningStep
+		if err := rows.Scan(&step.Step, &step.State, &step.Attempts, &step.LastError); err != nil {
+			return nil, fmt.Errorf("failed to scan provisioning step: %w", err)
+		}
+		steps = append(steps, &step)
+	}
+
+	return steps, rows.Err()
+}
+
+// runProvisioningStep menjalankan satu step provisioning. Semua step idempotent
+// sehingga aman diulang setelah kegagalan sebagian.
+func (m *TenantManager) runProvisioningStep(ctx context.Context, tenantID, step string) error {
+	switch step {
+	case domain.ProvisioningStepDBRow:
+		var exists bool
+		if err := m.db.QueryRow(ctx, "SELECT EXISTS(SELECT 1 FROM tenants WHERE id = $1)", tenantID).Scan(&exists); err != nil {
+			return fmt.Errorf("failed to check tenant row: %w", err)
+		}
+		if !exists {
+			return fmt.Errorf("tenant row does not exist")
+		}
+		return nil
+	case domain.ProvisioningStepPartition:
+		if _, err := m.db.Exec(ctx, "SELECT create_messages_partition($1)", tenantID); err != nil {
+			return fmt.Errorf("failed to create messages partition: %w", err)
+		}
+		return nil
+	case domain.ProvisioningStepConsumer:
+		return m.StartConsumer(ctx, tenantID)
+	}
+
+	ch, err := m.rabbitConn.Channel()
+	if err != nil {
+		return fmt.Errorf("failed to open channel: %v", err)
+	}
+	defer ch.Close()
+
+	dlConfig := rabbitmq.NewDefaultDeadLetterConfig()
+
+	switch step {
+	case domain.ProvisioningStepDLX:
+		return rabbitmq.SetupDeadLetterExchange(ch, dlConfig)
+	case domain.ProvisioningStepDLQ:
+		if _, err := rabbitmq.SetupDeadLetterQueue(ch, tenantID, dlConfig); err != nil {
+			return err
+		}
+		return rabbitmq.SetupRetryQueues(ch, tenantID, dlConfig)
+	case domain.ProvisioningStepQueue:
+		_, err := rabbitmq.DeclareTenantQueue(ch, tenantID, dlConfig)
+		return err
+	}
+
+	return fmt.Errorf("unknown provisioning step %q", step)
+}
+
+// provisioningBackoff mengembalikan jeda sebelum percobaan ulang setelah attempts kali gagal:
+// provisioningBaseBackoff yang berlipat ganda setiap kegagalan, dibatasi provisioningMaxBackoff
+func provisioningBackoff(attempts int) time.Duration {
+	backoff := provisioningBaseBackoff * time.Duration(1<<min(max(attempts, 1)-1, 10))
+	if backoff > provisioningMaxBackoff {
+		backoff = provisioningMaxBackoff
+	}
+	return backoff
+}
+
+// recordProvisioningFailure mencatat percobaan yang gagal, menjadwalkan percobaan ulang
+// dengan exponential backoff, dan menandai tenant sebagai failed dengan alasannya
+func (m *TenantManager) recordProvisioningFailure(tenantID string, step *domain.ProvisioningStep, cause error) {
+	attempts := step.Attempts + 1
+	backoff := provisioningBackoff(attempts)
+	now := time.Now()
+
+	// Gunakan context baru karena context request bisa sudah dibatalkan
+	ctx := context.Background()
+
+	if _, err := m.db.Exec(ctx, `
+		UPDATE tenant_provisioning
+		SET attempts = $1, last_error = $2, next_attempt_at = $3, updated_at = $4
+		WHERE tenant_id = $5 AND step = $6`,
+		attempts, cause.Error(), now.Add(backoff), now, tenantID, step.Step,
+	); err != nil {
+		logger.Log.WithFields(map[string]interface{}{
+			"tenant_id": tenantID,
+			"step":      step.Step,
+			"error":     err,
+		}).Error("Failed to record provisioning failure")
+	}
+
+	reason := fmt.Sprintf("provisioning step %s failed: %v", step.Step, cause)
+	if _, err := m.db.Exec(ctx, `
+		UPDATE tenants
+		SET status = $1, status_reason = $2, updated_at = $3
+		WHERE id = $4 AND status IN ($5, $6)`,
+		domain.TenantStatusFailed, reason, now, tenantID,
+		domain.TenantStatusProvisioning, domain.TenantStatusFailed,
+	); err != nil {
+		logger.Log.WithFields(map[string]interface{}{
+			"tenant_id": tenantID,
+			"error":     err,
+		}).Error("Failed to mark tenant as failed")
+	}
+
+	logger.Log.WithFields(map[string]interface{}{
+		"tenant_id":  tenantID,
+		"step":       step.Step,
+		"attempts":   attempts,
+		"next_retry": backoff,
+		"error":      cause,
+	}).Warn("Tenant provisioning step failed, will retry")
+}
+
+// provisioningLoop secara berkala menjalankan ulang provisioning tenant yang step-nya
+// masih pending dan sudah waktunya dicoba lagi
+func (m *TenantManager) provisioningLoop(ctx context.Context) {
+	ticker := time.NewTicker(provisioningInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-m.stopChan:
+			logger.Log.Info("Stopping provisioning reconciler")
+			return
+		case <-ctx.Done():
+			logger.Log.Info("Context cancelled, stopping provisioning reconciler")
+			return
+		case <-ticker.C:
+			m.reconcileProvisioning(ctx)
+		}
+	}
+}
+
+// reconcileProvisioning menjalankan Provision untuk setiap tenant dengan step yang jatuh tempo
+func (m *TenantManager) reconcileProvisioning(ctx context.Context) {
+	rows, err := m.db.Query(ctx, `
+		SELECT DISTINCT tenant_id
+		FROM tenant_provisioning
+		WHERE state = $1 AND next_attempt_at <= $2`,
+		domain.ProvisioningStatePending, time.Now(),
+	)
+	if err != nil {
+		logger.Log.WithField("error", err).Error("Failed to get pending provisioning steps")
+		return
+	}
+
+	var tenantIDs []string
+	for rows.Next() {
+		var tenantID string
+		if err := rows.Scan(&tenantID); err != nil {
+			logger.Log.WithField("error", err).Error("Failed to scan pending provisioning tenant")
+			continue
+		}
+		tenantIDs = append(tenantIDs, tenantID)
+	}
+	rows.Close()
+
+	for _, tenantID := range tenantIDs {
+		if err := m.Provision(ctx, tenantID); err != nil {
+			logger.Log.WithFields(map[string]interface{}{
+				"tenant_id": tenantID,
+				"error":     err,
+			}).Warn("Tenant provisioning retry failed")
+		}
+	}
+}
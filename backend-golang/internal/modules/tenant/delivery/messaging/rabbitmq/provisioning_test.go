@@ -0,0 +1,25 @@
+package rabbitmq
+
+import (
+	"testing"
+	"time"
+)
+
+func TestProvisioningBackoff(t *testing.T) {
+	want := map[int]time.Duration{
+		0:  5 * time.Second,
+		1:  5 * time.Second,
+		2:  10 * time.Second,
+		3:  20 * time.Second,
+		6:  160 * time.Second,
+		7:  5 * time.Minute,
+		11: 5 * time.Minute,
+		64: 5 * time.Minute,
+	}
+
+	for attempts, backoff := range want {
+		if got := provisioningBackoff(attempts); got != backoff {
+			t.Errorf("provisioningBackoff(%d) = %s, want %s", attempts, got, backoff)
+		}
+	}
+}
@@ -6,12 +6,17 @@ import (
 
 	"github.com/jatis/sample-stack-golang/internal/modules/tenant/domain"
 	"github.com/jatis/sample-stack-golang/pkg/logger"
+	"github.com/jatis/sample-stack-golang/pkg/rabbitmq"
 )
 
 // stopConsumerAndChannel menghentikan consumer dan menutup channel
 func (m *TenantManager) stopConsumerAndChannel(tenantID string, consumer *domain.TenantConsumer) error {
-	// Signal stop ke consumer
-	close(consumer.StopChannel)
+	// Signal stop ke consumer; StopChannel bisa sudah ditutup jika consumer sedang dipulihkan
+	select {
+	case <-consumer.StopChannel:
+	default:
+		close(consumer.StopChannel)
+	}
 
 	// Tunggu channel ditutup
 	time.Sleep(100 * time.Millisecond)
@@ -37,8 +42,9 @@ func (m *TenantManager) stopConsumerAndChannel(tenantID string, consumer *domain
 	return nil
 }
 
-// deleteQueue menghapus queue dari RabbitMQ
-func (m *TenantManager) deleteQueue(tenantID string) error {
+// deleteQueue menghapus main queue, DLQ, dan retry queue tenant dari RabbitMQ.
+// Jika ifEmpty bernilai true, penghapusan gagal bila main queue masih berisi pesan.
+func (m *TenantManager) deleteQueue(tenantID string, ifEmpty bool) error {
 	// Buat channel baru untuk delete queue
 	ch, err := m.rabbitConn.Channel()
 	if err != nil {
@@ -50,9 +56,9 @@ func (m *TenantManager) deleteQueue(tenantID string) error {
 	queueName := fmt.Sprintf("tenant.%s", tenantID)
 	_, err = ch.QueueDelete(
 		queueName,
-		false, // ifUnused
-		false, // ifEmpty
-		false, // noWait
+		false,   // ifUnused
+		ifEmpty, // ifEmpty
+		false,   // noWait
 	)
 	if err != nil {
 		return fmt.Errorf("failed to delete queue: %w", err)
@@ -73,6 +79,19 @@ func (m *TenantManager) deleteQueue(tenantID string) error {
 		}).Warn("Failed to delete dead-letter queue")
 	}
 
+	// Delete retry queues
+	dlConfig := rabbitmq.NewDefaultDeadLetterConfig()
+	for attempt := int32(1); attempt <= dlConfig.MaxRetries; attempt++ {
+		retryQueue := dlConfig.RetryQueueName(tenantID, attempt)
+		if _, err := ch.QueueDelete(retryQueue, false, false, false); err != nil {
+			logger.Log.WithFields(map[string]interface{}{
+				"tenant_id": tenantID,
+				"queue":     retryQueue,
+				"error":     err,
+			}).Warn("Failed to delete retry queue")
+		}
+	}
+
 	// Verify queue deletion
 	go m.verifyQueueDeletion(tenantID)
 
@@ -0,0 +1,241 @@
+package rabbitmq
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jatis/sample-stack-golang/internal/modules/tenant/domain"
+	"github.com/jatis/sample-stack-golang/pkg/logger"
+	"github.com/jatis/sample-stack-golang/pkg/rabbitmq"
+	"github.com/streadway/amqp"
+)
+
+// Reconcile mencocokkan tabel tenants, consumer yang berjalan di proses ini, dan queue
+// tenant di broker:
+//   - consumer yang belum berjalan untuk tenant di database dimulai (mis. tenant dibuat di replica lain)
+//   - consumer yang tenant-nya sudah tidak ada di database dihentikan
+//   - main queue atau DLQ yang hilang (mis. dihapus operator) dideklarasikan ulang dan consumer-nya dimulai ulang
+//
+// Dengan lease, memulai consumer berarti mencoba mengambil lease tenant, sehingga tenant
+// yang replica pemiliknya mati diambil alih setelah lease-nya kedaluwarsa.
+//
+// Tenant yang masih provisioning atau failed diurus oleh reconciler provisioning, tenant
+// deleting oleh reconciler drain, dan consumer yang sedang dipulihkan oleh self-healing.
+func (m *TenantManager) Reconcile(ctx context.Context) *domain.ReconcileReport {
+	report := &domain.ReconcileReport{
+		StartedAt:        time.Now(),
+		StartedConsumers: []string{},
+		StoppedConsumers: []string{},
+		RecreatedQueues:  []string{},
+		Errors:           []domain.ReconcileError{},
+	}
+	if m.leases != nil {
+		report.Replica = m.leases.Owner()
+	}
+	defer func() {
+		report.FinishedAt = time.Now()
+
+		m.reportMu.Lock()
+		m.lastReport = report
+		m.reportMu.Unlock()
+	}()
+
+	// Ambil snapshot consumer sebelum membaca database: baris tenant selalu di-commit
+	// sebelum consumer-nya dimulai, sehingga consumer di snapshot pasti terlihat di query
+	running, skipped := m.reconcileSnapshot()
+	report.Consumers = len(running)
+
+	statuses, err := m.tenantStatuses(ctx)
+	if err != nil {
+		report.Errors = append(report.Errors, domain.ReconcileError{Action: "list_tenants", Error: err.Error()})
+		return report
+	}
+	report.Tenants = len(statuses)
+
+	for tenantID, status := range statuses {
+		switch status {
+		case domain.TenantStatusProvisioning, domain.TenantStatusFailed, domain.TenantStatusDeleting:
+			continue
+		}
+		if _, ok := skipped[tenantID]; ok {
+			continue
+		}
+
+		missing, err := m.missingQueues(tenantID)
+		if err != nil {
+			report.Errors = append(report.Errors, domain.ReconcileError{TenantID: tenantID, Action: "inspect_queues", Error: err.Error()})
+			continue
+		}
+
+		_, isRunning := running[tenantID]
+		if len(missing) == 0 && isRunning {
+			continue
+		}
+
+		// consumer.StartConsumer mendeklarasikan ulang exchange, DLQ, retry queue, dan main queue
+		if err := m.StartConsumer(ctx, tenantID); err != nil {
+			report.Errors = append(report.Errors, domain.ReconcileError{TenantID: tenantID, Action: "start_consumer", Error: err.Error()})
+			continue
+		}
+		// Tenant yang lease-nya dipegang replica lain tidak dijalankan di sini; replica
+		// pemilik yang mendeklarasikan ulang queue-nya
+		if m.GetConsumer(tenantID) == nil {
+			continue
+		}
+		report.RecreatedQueues = append(report.RecreatedQueues, missing...)
+		if !isRunning {
+			report.StartedConsumers = append(report.StartedConsumers, tenantID)
+		}
+	}
+
+	for tenantID := range running {
+		if _, exists := statuses[tenantID]; exists {
+			continue
+		}
+		// Consumer bisa sudah dihentikan oleh penghapusan tenant setelah snapshot diambil
+		if m.GetConsumer(tenantID) == nil {
+			continue
+		}
+		if err := m.StopConsumer(ctx, tenantID); err != nil {
+			report.Errors = append(report.Errors, domain.ReconcileError{TenantID: tenantID, Action: "stop_consumer", Error: err.Error()})
+			continue
+		}
+		report.StoppedConsumers = append(report.StoppedConsumers, tenantID)
+	}
+
+	fields := map[string]interface{}{
+		"tenants":           report.Tenants,
+		"consumers":         report.Consumers,
+		"started_consumers": len(report.StartedConsumers),
+		"stopped_consumers": len(report.StoppedConsumers),
+		"recreated_queues":  len(report.RecreatedQueues),
+		"errors":            len(report.Errors),
+	}
+	if len(report.StartedConsumers)+len(report.StoppedConsumers)+len(report.RecreatedQueues)+len(report.Errors) > 0 {
+		logger.Log.WithFields(fields).Info("Tenant reconcile corrected drift")
+	} else {
+		logger.Log.WithFields(fields).Debug("Tenant reconcile found no drift")
+	}
+
+	return report
+}
+
+// LastReconcileReport mengembalikan hasil reconcile terakhir, atau nil jika belum pernah berjalan
+func (m *TenantManager) LastReconcileReport() *domain.ReconcileReport {
+	m.reportMu.RLock()
+	defer m.reportMu.RUnlock()
+
+	return m.lastReport
+}
+
+// reconcileSnapshot mengembalikan tenant yang consumer-nya berjalan dan tenant yang sedang
+// dipulihkan atau diprovision sehingga tidak boleh disentuh reconciler
+func (m *TenantManager) reconcileSnapshot() (running, skipped map[string]struct{}) {
+	running = make(map[string]struct{})
+	skipped = make(map[string]struct{})
+
+	m.mu.RLock()
+	for tenantID := range m.consumers {
+		running[tenantID] = struct{}{}
+	}
+	for tenantID := range m.recovering {
+		skipped[tenantID] = struct{}{}
+	}
+	m.mu.RUnlock()
+
+	m.provisionMu.Lock()
+	for tenantID := range m.provisioning {
+		skipped[tenantID] = struct{}{}
+	}
+	m.provisionMu.Unlock()
+
+	return running, skipped
+}
+
+// tenantStatuses membaca status semua tenant dari database
+func (m *TenantManager) tenantStatuses(ctx context.Context) (map[string]string, error) {
+	rows, err := m.db.Query(ctx, "SELECT id, status FROM tenants")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list tenants: %w", err)
+	}
+	defer rows.Close()
+
+	statuses := make(map[string]string)
+	for rows.Next() {
+		var tenantID, status string
+		if err := rows.Scan(&tenantID, &status); err != nil {
+			return nil, fmt.Errorf("failed to scan tenant: %w", err)
+		}
+		statuses[tenantID] = status
+	}
+
+	return statuses, rows.Err()
+}
+
+// missingQueues mengembalikan main queue dan DLQ tenant yang tidak ada di broker
+func (m *TenantManager) missingQueues(tenantID string) ([]string, error) {
+	dlConfig := rabbitmq.NewDefaultDeadLetterConfig()
+
+	var missing []string
+	for _, queueName := range []string{fmt.Sprintf("tenant.%s", tenantID), dlConfig.DeadLetterQueueName(tenantID)} {
+		exists, err := m.queueExists(queueName)
+		if err != nil {
+			return nil, err
+		}
+		if !exists {
+			missing = append(missing, queueName)
+		}
+	}
+
+	return missing, nil
+}
+
+// queueExists memeriksa keberadaan queue dengan passive declare. Broker menutup channel
+// jika queue tidak ada, sehingga setiap pemeriksaan memakai channel sendiri.
+func (m *TenantManager) queueExists(queueName string) (bool, error) {
+	ch, err := m.rabbitConn.Channel()
+	if err != nil {
+		return false, fmt.Errorf("failed to open channel for queue inspection: %w", err)
+	}
+	defer ch.Close()
+
+	_, err = ch.QueueDeclarePassive(
+		queueName,
+		true,  // durable
+		false, // autoDelete
+		false, // exclusive
+		false, // noWait
+		nil,   // args
+	)
+
+	var amqpErr *amqp.Error
+	if errors.As(err, &amqpErr) && amqpErr.Code == amqp.NotFound {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to inspect queue %s: %w", queueName, err)
+	}
+
+	return true, nil
+}
+
+// reconcileLoop menjalankan Reconcile secara berkala
+func (m *TenantManager) reconcileLoop(ctx context.Context) {
+	ticker := time.NewTicker(m.health.ReconcileInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-m.stopChan:
+			logger.Log.Info("Stopping tenant reconciler")
+			return
+		case <-ctx.Done():
+			logger.Log.Info("Context cancelled, stopping tenant reconciler")
+			return
+		case <-ticker.C:
+			m.Reconcile(ctx)
+		}
+	}
+}
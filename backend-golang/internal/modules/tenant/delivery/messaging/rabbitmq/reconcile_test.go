@@ -0,0 +1,37 @@
+package rabbitmq
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/jatis/sample-stack-golang/internal/modules/tenant/domain"
+)
+
+func TestReconcileSnapshot(t *testing.T) {
+	m := &TenantManager{
+		consumers: map[string]*domain.TenantConsumer{
+			"running":    {TenantID: "running"},
+			"recovering": {TenantID: "recovering"},
+		},
+		recovering:   map[string]struct{}{"recovering": {}},
+		provisioning: map[string]struct{}{"provisioning": {}},
+	}
+
+	running, skipped := m.reconcileSnapshot()
+
+	wantRunning := map[string]struct{}{"running": {}, "recovering": {}}
+	if !reflect.DeepEqual(running, wantRunning) {
+		t.Errorf("running = %v, want %v", running, wantRunning)
+	}
+	// Consumer yang sedang dipulihkan atau diprovision tidak disentuh reconciler
+	wantSkipped := map[string]struct{}{"recovering": {}, "provisioning": {}}
+	if !reflect.DeepEqual(skipped, wantSkipped) {
+		t.Errorf("skipped = %v, want %v", skipped, wantSkipped)
+	}
+
+	// Snapshot tidak berubah ketika consumer berubah setelahnya
+	delete(m.consumers, "running")
+	if _, ok := running["running"]; !ok {
+		t.Error("snapshot shares the consumers map")
+	}
+}
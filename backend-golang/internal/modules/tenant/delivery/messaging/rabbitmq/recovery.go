@@ -0,0 +1,192 @@
+package rabbitmq
+
+import (
+	"context"
+	"time"
+
+	"github.com/jatis/sample-stack-golang/internal/modules/tenant/delivery/messaging/rabbitmq/consumer"
+	"github.com/jatis/sample-stack-golang/internal/modules/tenant/domain"
+	"github.com/jatis/sample-stack-golang/pkg/logger"
+)
+
+const (
+	// recoveryInitialInterval adalah jeda awal sebelum mencoba ulang consumer yang gagal dipulihkan
+	recoveryInitialInterval = time.Second
+
+	// recoveryMaxInterval adalah batas atas jeda percobaan ulang pemulihan consumer
+	recoveryMaxInterval = 30 * time.Second
+)
+
+// recoverConsumers dipanggil setelah koneksi RabbitMQ pulih. Channel lama ikut mati
+// bersama koneksi, sehingga setiap consumer yang terdaftar dilepas lalu dimulai ulang:
+// exchange, DLQ, retry queue, dan main queue dideklarasikan ulang oleh consumer.StartConsumer.
+// m.mu hanya dipegang untuk mengambil snapshot; menghentikan dan memulai consumer
+// dilakukan di luar lock agar API dan health check tidak tertahan selama pemulihan.
+func (m *TenantManager) recoverConsumers() {
+	m.mu.Lock()
+	stale := make(map[string]*domain.TenantConsumer)
+	for tenantID, c := range m.consumers {
+		if c.State() != domain.ConsumerStateRecovering {
+			m.markRecovering(tenantID, c, "connection lost")
+			stale[tenantID] = c
+		}
+	}
+	tenantIDs := make([]string, 0, len(m.recovering))
+	for tenantID := range m.recovering {
+		tenantIDs = append(tenantIDs, tenantID)
+	}
+	m.mu.Unlock()
+
+	logger.Log.WithFields(map[string]interface{}{
+		"consumer_count": len(tenantIDs),
+	}).Info("Recovering tenant consumers after RabbitMQ reconnect")
+
+	for tenantID, c := range stale {
+		m.stopStaleConsumer(tenantID, c)
+	}
+
+	for _, tenantID := range tenantIDs {
+		if err := m.restartRecoveringConsumer(tenantID); err != nil {
+			logger.Log.WithFields(map[string]interface{}{
+				"tenant_id": tenantID,
+				"error":     err,
+			}).Error("Failed to recover consumer, will retry")
+			go m.retryRecovery(tenantID)
+		}
+	}
+}
+
+// onChannelFailure dipanggil oleh watcher channel consumer ketika channel ditutup
+// atau consumer di-cancel oleh broker
+func (m *TenantManager) onChannelFailure(c *domain.TenantConsumer, reason string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	// Abaikan consumer yang sudah diganti atau dihentikan
+	if current, exists := m.consumers[c.TenantID]; !exists || current != c {
+		return
+	}
+
+	c.IsActive.Store(false)
+	c.SetState(domain.ConsumerStateUnhealthy)
+	c.SetLastError(reason)
+
+	// Jika seluruh koneksi terputus, recoverConsumers akan dipanggil setelah reconnect
+	if m.rabbitConn.IsClosed() {
+		return
+	}
+
+	// Consumer dibuat ulang dengan backoff agar channel yang terus gagal tidak
+	// menyebabkan restart beruntun
+	m.detachForRecovery(c.TenantID, c, reason)
+	go m.retryRecovery(c.TenantID)
+}
+
+// detachForRecovery menghentikan worker dan channel consumer lama, tetapi tetap
+// menyimpannya di map dengan state recovering agar terlihat di consumers API.
+// Pemanggil harus memegang m.mu.
+func (m *TenantManager) detachForRecovery(tenantID string, c *domain.TenantConsumer, reason string) {
+	m.stopStaleConsumer(tenantID, c)
+	m.markRecovering(tenantID, c, reason)
+}
+
+// markRecovering menandai consumer sebagai recovering tanpa menghentikan channel-nya.
+// Pemanggil harus memegang m.mu.
+func (m *TenantManager) markRecovering(tenantID string, c *domain.TenantConsumer, reason string) {
+	c.IsActive.Store(false)
+	c.SetState(domain.ConsumerStateRecovering)
+	c.SetLastError(reason)
+	m.recovering[tenantID] = struct{}{}
+}
+
+// stopStaleConsumer menghentikan worker dan channel consumer yang akan diganti
+func (m *TenantManager) stopStaleConsumer(tenantID string, c *domain.TenantConsumer) {
+	if err := m.stopConsumerAndChannel(tenantID, c); err != nil {
+		logger.Log.WithFields(map[string]interface{}{
+			"tenant_id": tenantID,
+			"error":     err,
+		}).Warn("Failed to detach stale consumer")
+	}
+}
+
+// restartRecoveringConsumer memulai ulang consumer tenant yang sedang dipulihkan.
+// Consumer baru dibuat tanpa memegang m.mu; jika selama itu tenant sudah dihentikan,
+// di-decommission, atau dipulihkan oleh jalur lain, consumer baru langsung dihentikan.
+func (m *TenantManager) restartRecoveringConsumer(tenantID string) error {
+	newConsumer, err := consumer.StartConsumer(
+		context.Background(),
+		tenantID,
+		m.rabbitConn,
+		m.db,
+		m.addToWaitGroup,
+		m.startWorker,
+		m.onChannelFailure,
+	)
+
+	m.mu.Lock()
+	if err != nil {
+		if stale, exists := m.consumers[tenantID]; exists {
+			stale.SetLastError(err.Error())
+		}
+		m.mu.Unlock()
+		return err
+	}
+	if _, pending := m.recovering[tenantID]; !pending {
+		m.mu.Unlock()
+		m.stopStaleConsumer(tenantID, newConsumer)
+		return nil
+	}
+	m.consumers[tenantID] = newConsumer
+	delete(m.recovering, tenantID)
+	m.mu.Unlock()
+
+	logger.Log.WithFields(map[string]interface{}{
+		"tenant_id": tenantID,
+	}).Info("Consumer recovered")
+
+	return nil
+}
+
+// retryRecovery mencoba ulang pemulihan consumer dengan exponential backoff sampai
+// berhasil, tenant dihentikan/dihapus, atau manager dihentikan
+func (m *TenantManager) retryRecovery(tenantID string) {
+	interval := recoveryInitialInterval
+
+	for {
+		select {
+		case <-m.stopChan:
+			return
+		case <-time.After(interval):
+		}
+
+		m.mu.Lock()
+		if _, pending := m.recovering[tenantID]; !pending {
+			// Sudah pulih, dihentikan, atau di-decommission
+			m.mu.Unlock()
+			return
+		}
+		if m.rabbitConn.IsClosed() {
+			// Koneksi terputus lagi; recoverConsumers akan dipanggil setelah reconnect berikutnya
+			m.mu.Unlock()
+			return
+		}
+		m.mu.Unlock()
+
+		err := m.restartRecoveringConsumer(tenantID)
+
+		if err == nil {
+			return
+		}
+
+		interval *= 2
+		if interval > recoveryMaxInterval {
+			interval = recoveryMaxInterval
+		}
+
+		logger.Log.WithFields(map[string]interface{}{
+			"tenant_id":  tenantID,
+			"next_retry": interval.String(),
+			"error":      err,
+		}).Warn("Failed to recover consumer")
+	}
+}
@@ -1,10 +1,13 @@
 package domain
 
 import (
+	"encoding/json"
+	"sync"
 	"sync/atomic"
 	"time"
 
 	"github.com/streadway/amqp"
+	"golang.org/x/time/rate"
 )
 
 // Tenant represents a tenant in the system
@@ -13,11 +16,71 @@ type Tenant struct {
 	Name        string    `json:"name"`
 	Description string    `json:"description"`
 	Status      string    `json:"status"`
+	// StatusReason explains the current status, e.g. the provisioning step that failed
+	StatusReason string   `json:"status_reason,omitempty"`
 	Workers     int       `json:"workers"`
+	Prefetch    int       `json:"prefetch"`
+	// DedupEnabled makes workers skip messages whose MessageId was already processed
+	DedupEnabled bool     `json:"dedup_enabled"`
+	// PublishRate is the publish rate limit in messages per second (0 = configured default)
+	PublishRate  float64  `json:"publish_rate"`
+	// PublishBurst is the publish rate limit bucket size (0 = derived from the rate)
+	PublishBurst int      `json:"publish_burst"`
+	// ThroughputRate caps how many messages per second the tenant workers process (0 = unlimited)
+	ThroughputRate  float64 `json:"throughput_rate"`
+	// ThroughputBurst is the number of messages that may be processed back to back (0 = derived from the rate)
+	ThroughputBurst int     `json:"throughput_burst"`
 	CreatedAt   time.Time `json:"created_at"`
 	UpdatedAt   time.Time `json:"updated_at"`
+	// Deletion is only set while a drain-then-delete is in progress or after it was aborted
+	Deletion *DeletionProgress `json:"deletion,omitempty"`
+	// Provisioning is only set while the tenant is provisioning or failed
+	Provisioning []*ProvisioningStep `json:"provisioning,omitempty"`
 }
 
+// Drain states for DeletionProgress
+const (
+	DrainStateDraining  = "draining"
+	DrainStateCompleted = "completed"
+	DrainStateTimedOut  = "timed_out"
+	DrainStateFailed    = "failed"
+)
+
+// DeletionProgress represents the progress of a drain-then-delete tenant deletion
+type DeletionProgress struct {
+	State           string    `json:"state"`
+	// PreviousStatus is restored if the drain times out or fails
+	PreviousStatus  string    `json:"previous_status"`
+	PendingMessages int       `json:"pending_messages"`
+	StartedAt       time.Time `json:"started_at"`
+	Deadline        time.Time `json:"deadline"`
+	FinishedAt      time.Time `json:"finished_at,omitempty"`
+	Error           string    `json:"error,omitempty"`
+}
+
+// Consumer states reported by the consumers API
+const (
+	// ConsumerStateRunning means the consumer channel is open and consuming
+	ConsumerStateRunning = "running"
+	// ConsumerStateUnhealthy means the channel was closed or the consumer was cancelled by the broker
+	ConsumerStateUnhealthy = "unhealthy"
+	// ConsumerStateRecovering means the manager is recreating the consumer
+	ConsumerStateRecovering = "recovering"
+	// ConsumerStatePaused means the consumer tag is cancelled; messages accumulate in the queue
+	ConsumerStatePaused = "paused"
+)
+
+// Consumer health reported by the consumers API
+const (
+	// ConsumerHealthHealthy means the consumer received or processed a message recently
+	ConsumerHealthHealthy = "healthy"
+	// ConsumerHealthIdle means no heartbeat was seen for longer than the idle threshold,
+	// usually because the queue is empty. Idle consumers are never restarted.
+	ConsumerHealthIdle = "idle"
+	// ConsumerHealthStuck means a message has been in progress longer than the stuck threshold
+	ConsumerHealthStuck = "stuck"
+)
+
 // TenantConsumer represents a RabbitMQ consumer for a tenant
 type TenantConsumer struct {
 	TenantID      string         `json:"tenant_id"`
@@ -25,14 +88,209 @@ type TenantConsumer struct {
 	ConsumerTag   string         `json:"consumer_tag"`
 	Channel       *amqp.Channel  `json:"-"`
 	StopChannel   chan struct{}  `json:"-"`
-	IsActive      bool           `json:"is_active"`
-	LastHeartbeat time.Time      `json:"last_heartbeat"`
+	IsActive      atomic.Bool    `json:"is_active" swaggertype:"boolean"`
+	// state is the lifecycle state of the consumer, read and written through State/SetState
+	state         atomic.Value
+	// lastError is the reason the consumer channel was last closed or cancelled
+	lastError     atomic.Value
 	ErrorChannel  chan error     `json:"-"`
 	WorkerCount   atomic.Int32   `json:"worker_count" swaggertype:"integer"`
+	// Prefetch is the QoS prefetch count applied to the consumer channel
+	Prefetch      atomic.Int32   `json:"prefetch" swaggertype:"integer"`
+	// Paused is set while the consumer tag is cancelled; workers requeue buffered deliveries
+	Paused        atomic.Bool    `json:"-"`
+	// Dedup enables the Redis deduplication stage in workers; it can be toggled while running
+	Dedup         atomic.Bool    `json:"-"`
+	// Throttle limits how fast workers process deliveries; workers wait on it before each message
+	Throttle      *rate.Limiter  `json:"-"`
 	MessageChan   chan amqp.Delivery `json:"-"`
+	// InFlight is the number of messages currently being processed by workers
+	InFlight atomic.Int32 `json:"-"`
+	// WorkerStopChannels berisi stop channel per worker yang sedang berjalan, urut dari worker tertua
+	WorkerStopChannels []chan struct{} `json:"-"`
+
+	// lastHeartbeat is the unix nano time of the last heartbeat from the forwarder or a worker
+	lastHeartbeat atomic.Int64
+	// health is the last health classified by the manager health check
+	health atomic.Value
+	// busySince maps worker ID to the time the worker started its current message
+	busySince sync.Map
+	// nextWorkerID is the ID given to the next spawned worker; IDs are never reused
+	nextWorkerID atomic.Int32
+}
+
+// Heartbeat records that the forwarding loop or a worker is alive
+func (c *TenantConsumer) Heartbeat() {
+	c.lastHeartbeat.Store(time.Now().UnixNano())
+}
+
+// LastHeartbeat returns the time of the last heartbeat
+func (c *TenantConsumer) LastHeartbeat() time.Time {
+	nanos := c.lastHeartbeat.Load()
+	if nanos == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, nanos)
+}
+
+// NextWorkerID returns a new worker ID. IDs are not reused after a scale-down, so a
+// stopped worker still finishing its message never shares busy state with a new worker.
+func (c *TenantConsumer) NextWorkerID() int {
+	return int(c.nextWorkerID.Add(1) - 1)
+}
+
+// BeginMessage marks a worker as busy with a message and records a heartbeat
+func (c *TenantConsumer) BeginMessage(workerID int) {
+	c.busySince.Store(workerID, time.Now())
+	c.Heartbeat()
+}
+
+// EndMessage marks a worker as no longer busy and records a heartbeat
+func (c *TenantConsumer) EndMessage(workerID int) {
+	c.busySince.Delete(workerID)
+	c.Heartbeat()
+}
+
+// OldestMessageStart returns when the longest running in-progress message started
+func (c *TenantConsumer) OldestMessageStart() (time.Time, bool) {
+	var oldest time.Time
+	c.busySince.Range(func(_, value interface{}) bool {
+		if started := value.(time.Time); oldest.IsZero() || started.Before(oldest) {
+			oldest = started
+		}
+		return true
+	})
+	return oldest, !oldest.IsZero()
+}
+
+// SetState stores the lifecycle state of the consumer
+func (c *TenantConsumer) SetState(state string) {
+	c.state.Store(state)
+}
+
+// State returns the lifecycle state of the consumer
+func (c *TenantConsumer) State() string {
+	state, _ := c.state.Load().(string)
+	return state
+}
+
+// SetLastError stores the reason the consumer channel was last closed or cancelled
+func (c *TenantConsumer) SetLastError(reason string) {
+	c.lastError.Store(reason)
+}
+
+// LastError returns the reason the consumer channel was last closed or cancelled
+func (c *TenantConsumer) LastError() string {
+	reason, _ := c.lastError.Load().(string)
+	return reason
+}
+
+// SetHealth stores the health classified by the manager health check
+func (c *TenantConsumer) SetHealth(health string) {
+	c.health.Store(health)
+}
+
+// Health returns the last classified health, defaulting to healthy
+func (c *TenantConsumer) Health() string {
+	if health, ok := c.health.Load().(string); ok {
+		return health
+	}
+	return ConsumerHealthHealthy
+}
+
+// MarshalJSON encodes a snapshot of the consumer including its liveness information
+func (c *TenantConsumer) MarshalJSON() ([]byte, error) {
+	snapshot := struct {
+		TenantID      string     `json:"tenant_id"`
+		QueueName     string     `json:"queue_name"`
+		ConsumerTag   string     `json:"consumer_tag"`
+		IsActive      bool       `json:"is_active"`
+		State         string     `json:"state"`
+		Health        string     `json:"health"`
+		LastError     string     `json:"last_error,omitempty"`
+		LastHeartbeat time.Time  `json:"last_heartbeat"`
+		BusySince     *time.Time `json:"busy_since,omitempty"`
+		WorkerCount   int32      `json:"worker_count"`
+		InFlight      int32      `json:"in_flight"`
+		Prefetch      int        `json:"prefetch"`
+		DedupEnabled  bool       `json:"dedup_enabled"`
+		// ThroughputRate is omitted when consumption is not throttled
+		ThroughputRate  float64  `json:"throughput_rate,omitempty"`
+		ThroughputBurst int      `json:"throughput_burst,omitempty"`
+	}{
+		TenantID:      c.TenantID,
+		QueueName:     c.QueueName,
+		ConsumerTag:   c.ConsumerTag,
+		IsActive:      c.IsActive.Load(),
+		State:         c.State(),
+		Health:        c.Health(),
+		LastError:     c.LastError(),
+		LastHeartbeat: c.LastHeartbeat(),
+		WorkerCount:   c.WorkerCount.Load(),
+		InFlight:      c.InFlight.Load(),
+		Prefetch:      int(c.Prefetch.Load()),
+		DedupEnabled:  c.Dedup.Load(),
+	}
+	if started, ok := c.OldestMessageStart(); ok {
+		snapshot.BusySince = &started
+	}
+	if c.Throttle != nil && c.Throttle.Limit() != rate.Inf {
+		snapshot.ThroughputRate = float64(c.Throttle.Limit())
+		snapshot.ThroughputBurst = c.Throttle.Burst()
+	}
+
+	return json.Marshal(snapshot)
 }
 
 // ConcurrencyConfig represents the concurrency configuration for a tenant
 type ConcurrencyConfig struct {
 	Workers int `json:"workers"`
-}
\ No newline at end of file
+	// Prefetch is the maximum number of unacked messages RabbitMQ delivers to the
+	// tenant consumer. Zero keeps the current value.
+	Prefetch int `json:"prefetch,omitempty"`
+}
+
+// DedupConfig represents the consumer-side deduplication configuration for a tenant
+type DedupConfig struct {
+	Enabled bool `json:"enabled"`
+}
+
+// PublishLimitConfig represents the publish rate limit of a tenant. Rate is in
+// messages per second; zero falls back to the configured default limit.
+type PublishLimitConfig struct {
+	Rate  float64 `json:"rate"`
+	Burst int     `json:"burst,omitempty"`
+}
+
+// ThroughputConfig represents the consumption rate limit of a tenant. Rate is in
+// messages per second processed by the tenant workers; zero removes the limit.
+type ThroughputConfig struct {
+	Rate  float64 `json:"rate"`
+	Burst int     `json:"burst,omitempty"`
+}
+
+// PublishMessageRequest represents the envelope form of a publish request. A body is
+// only read as an envelope when it has a "payload" field and no fields other than the
+// ones below; any other body is treated as the payload itself for backwards compatibility.
+type PublishMessageRequest struct {
+	Payload json.RawMessage `json:"payload" swaggertype:"object"`
+	// MessageID is generated as a UUID when empty
+	MessageID     string                 `json:"message_id,omitempty"`
+	Type          string                 `json:"type,omitempty"`
+	Priority      uint8                  `json:"priority,omitempty"`
+	CorrelationID string                 `json:"correlation_id,omitempty"`
+	Headers       map[string]interface{} `json:"headers,omitempty"`
+}
+
+// DLQMessage represents a dead-lettered message returned by the DLQ browsing API
+type DLQMessage struct {
+	MessageID     string                   `json:"message_id"`
+	CorrelationID string                   `json:"correlation_id,omitempty"`
+	Type          string                   `json:"type,omitempty"`
+	Timestamp     time.Time                `json:"timestamp"`
+	Body          json.RawMessage          `json:"body" swaggertype:"object"`
+	Headers       map[string]interface{}   `json:"headers"`
+	Deaths        []map[string]interface{} `json:"x_death"`
+	RetryCount    int32                    `json:"retry_count"`
+	LastError     string                   `json:"last_error,omitempty"`
+}
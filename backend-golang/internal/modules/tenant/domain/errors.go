@@ -0,0 +1,11 @@
+package domain
+
+import "errors"
+
+var (
+	// ErrQueueNotFound dikembalikan jika queue tenant tidak ada sehingga pesan tidak dapat di-route
+	ErrQueueNotFound = errors.New("tenant queue not found")
+
+	// ErrPublishNotConfirmed dikembalikan jika broker menolak (nack) atau tidak mengkonfirmasi pesan
+	ErrPublishNotConfirmed = errors.New("message was not confirmed by the broker")
+)
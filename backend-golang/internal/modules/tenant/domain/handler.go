@@ -0,0 +1,25 @@
+package domain
+
+import (
+	"context"
+	"errors"
+
+	"github.com/streadway/amqp"
+)
+
+// ErrNoMessageHandler dikembalikan ketika tidak ada handler yang cocok untuk sebuah pesan
+var ErrNoMessageHandler = errors.New("no message handler registered")
+
+// MessageHandler memproses satu pesan yang diterima oleh worker pool tenant.
+// Error yang dikembalikan akan diproses dengan retry logic dan DLQ.
+type MessageHandler interface {
+	Handle(ctx context.Context, tenantID string, msg amqp.Delivery) error
+}
+
+// MessageHandlerFunc adalah adapter agar fungsi biasa dapat dipakai sebagai MessageHandler
+type MessageHandlerFunc func(ctx context.Context, tenantID string, msg amqp.Delivery) error
+
+// Handle memanggil f(ctx, tenantID, msg)
+func (f MessageHandlerFunc) Handle(ctx context.Context, tenantID string, msg amqp.Delivery) error {
+	return f(ctx, tenantID, msg)
+}
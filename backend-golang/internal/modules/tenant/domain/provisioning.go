@@ -0,0 +1,39 @@
+package domain
+
+import "time"
+
+// Provisioning steps, in the order they are executed
+const (
+	ProvisioningStepDBRow     = "db_row"
+	ProvisioningStepPartition = "partition"
+	ProvisioningStepDLX       = "dlx"
+	ProvisioningStepDLQ       = "dlq"
+	ProvisioningStepQueue     = "main_queue"
+	ProvisioningStepConsumer  = "consumer"
+)
+
+// ProvisioningSteps lists all provisioning steps in execution order
+var ProvisioningSteps = []string{
+	ProvisioningStepDBRow,
+	ProvisioningStepPartition,
+	ProvisioningStepDLX,
+	ProvisioningStepDLQ,
+	ProvisioningStepQueue,
+	ProvisioningStepConsumer,
+}
+
+// Provisioning step states
+const (
+	ProvisioningStatePending = "pending"
+	ProvisioningStateDone    = "done"
+)
+
+// ProvisioningStep is one row of the tenant provisioning outbox
+type ProvisioningStep struct {
+	Step          string    `json:"step"`
+	State         string    `json:"state"`
+	Attempts      int       `json:"attempts"`
+	LastError     string    `json:"last_error,omitempty"`
+	NextAttemptAt time.Time `json:"next_attempt_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+}
@@ -0,0 +1,30 @@
+package domain
+
+import "time"
+
+// ReconcileReport is the result of one reconciliation between the tenants table,
+// the consumers running in this process and the tenant queues on the broker
+type ReconcileReport struct {
+	// Replica is the replica that ran the reconciliation when lease ownership is enabled
+	Replica    string    `json:"replica,omitempty"`
+	StartedAt  time.Time `json:"started_at"`
+	FinishedAt time.Time `json:"finished_at"`
+	// Tenants is the number of tenants in the database
+	Tenants int `json:"tenants"`
+	// Consumers is the number of consumers running before the reconciliation
+	Consumers int `json:"consumers"`
+	// StartedConsumers lists tenants whose missing consumer was started
+	StartedConsumers []string `json:"started_consumers"`
+	// StoppedConsumers lists consumers stopped because their tenant no longer exists
+	StoppedConsumers []string `json:"stopped_consumers"`
+	// RecreatedQueues lists queues that were missing on the broker and declared again
+	RecreatedQueues []string         `json:"recreated_queues"`
+	Errors          []ReconcileError `json:"errors"`
+}
+
+// ReconcileError is a reconcile action that failed for a tenant
+type ReconcileError struct {
+	TenantID string `json:"tenant_id,omitempty"`
+	Action   string `json:"action"`
+	Error    string `json:"error"`
+}
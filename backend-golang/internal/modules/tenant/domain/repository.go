@@ -2,6 +2,7 @@ package domain
 
 import (
 	"context"
+	"time"
 )
 
 // TenantRepository interface untuk operasi database tenant
@@ -11,5 +12,12 @@ type TenantRepository interface {
 	Update(ctx context.Context, tenant *Tenant) error
 	Delete(ctx context.Context, id string) error
 	List(ctx context.Context) ([]*Tenant, error)
-	UpdateConcurrency(ctx context.Context, id string, workers int) error
+	UpdateConcurrency(ctx context.Context, id string, workers, prefetch int) error
+	UpdateDedup(ctx context.Context, id string, enabled bool) error
+	UpdatePublishLimit(ctx context.Context, id string, rate float64, burst int) error
+	UpdateThroughput(ctx context.Context, id string, rate float64, burst int) error
+	UpdateStatus(ctx context.Context, id string, status string) error
+	GetProvisioning(ctx context.Context, id string) ([]*ProvisioningStep, error)
+	StartDrain(ctx context.Context, id, previousStatus string, deadline time.Time) error
+	GetDrain(ctx context.Context, id string) (*DeletionProgress, error)
 }
\ No newline at end of file
@@ -0,0 +1,54 @@
+package domain
+
+const (
+	// TenantStatusProvisioning is set while the tenant queues and consumer are being created
+	TenantStatusProvisioning = "provisioning"
+	// TenantStatusActive means the tenant accepts publishes and its consumer is running
+	TenantStatusActive = "active"
+	// TenantStatusPaused means consumption is paused; publishes are accepted and accumulate in the queue
+	TenantStatusPaused = "paused"
+	// TenantStatusSuspended means consumption is stopped and new publishes are refused
+	TenantStatusSuspended = "suspended"
+	// TenantStatusDeleting is set while a tenant is being drained before deletion
+	TenantStatusDeleting = "deleting"
+	// TenantStatusFailed means the tenant consumer could not be started
+	TenantStatusFailed = "failed"
+)
+
+// tenantStatusTransitions lists the statuses a tenant may move to from each status.
+// Deleting has no outgoing transitions: the tenant is either removed or the drain is
+// aborted, in which case the previous status is restored directly.
+var tenantStatusTransitions = map[string][]string{
+	TenantStatusProvisioning: {TenantStatusActive, TenantStatusFailed, TenantStatusDeleting},
+	TenantStatusActive:       {TenantStatusPaused, TenantStatusSuspended, TenantStatusFailed, TenantStatusDeleting},
+	TenantStatusPaused:       {TenantStatusActive, TenantStatusSuspended, TenantStatusFailed, TenantStatusDeleting},
+	TenantStatusSuspended:    {TenantStatusActive, TenantStatusFailed, TenantStatusDeleting},
+	TenantStatusFailed:       {TenantStatusActive, TenantStatusDeleting},
+	TenantStatusDeleting:     {},
+}
+
+// IsValidTenantStatus returns true if status is one of the defined tenant statuses
+func IsValidTenantStatus(status string) bool {
+	_, ok := tenantStatusTransitions[status]
+	return ok
+}
+
+// CanTransitionTenantStatus returns true if a tenant may move from one status to another
+func CanTransitionTenantStatus(from, to string) bool {
+	for _, next := range tenantStatusTransitions[from] {
+		if next == to {
+			return true
+		}
+	}
+	return false
+}
+
+// IsConsumptionStopped returns true if the tenant consumer must not consume in this status
+func IsConsumptionStopped(status string) bool {
+	return status == TenantStatusPaused || status == TenantStatusSuspended
+}
+
+// TenantStatusUpdate is the request body for changing the status of a tenant
+type TenantStatusUpdate struct {
+	Status string `json:"status"`
+}
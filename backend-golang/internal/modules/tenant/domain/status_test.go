@@ -0,0 +1,78 @@
+package domain
+
+import "testing"
+
+func TestCanTransitionTenantStatus(t *testing.T) {
+	allowed := [][2]string{
+		{TenantStatusProvisioning, TenantStatusActive},
+		{TenantStatusProvisioning, TenantStatusFailed},
+		{TenantStatusActive, TenantStatusPaused},
+		{TenantStatusActive, TenantStatusSuspended},
+		{TenantStatusPaused, TenantStatusActive},
+		{TenantStatusPaused, TenantStatusSuspended},
+		{TenantStatusSuspended, TenantStatusActive},
+		{TenantStatusFailed, TenantStatusActive},
+		{TenantStatusFailed, TenantStatusDeleting},
+		{TenantStatusActive, TenantStatusDeleting},
+	}
+	for _, tr := range allowed {
+		if !CanTransitionTenantStatus(tr[0], tr[1]) {
+			t.Errorf("%s -> %s should be allowed", tr[0], tr[1])
+		}
+	}
+
+	refused := [][2]string{
+		// No self transitions
+		{TenantStatusActive, TenantStatusActive},
+		// Provisioning is only entered on create
+		{TenantStatusActive, TenantStatusProvisioning},
+		{TenantStatusFailed, TenantStatusProvisioning},
+		// A suspended tenant has to be resumed before it can be paused
+		{TenantStatusSuspended, TenantStatusPaused},
+		{TenantStatusFailed, TenantStatusPaused},
+		// Deleting is terminal; an aborted drain restores the previous status directly
+		{TenantStatusDeleting, TenantStatusActive},
+		{TenantStatusDeleting, TenantStatusFailed},
+		// Unknown statuses
+		{"archived", TenantStatusActive},
+		{TenantStatusActive, "archived"},
+		{"", TenantStatusActive},
+	}
+	for _, tr := range refused {
+		if CanTransitionTenantStatus(tr[0], tr[1]) {
+			t.Errorf("%s -> %s should be refused", tr[0], tr[1])
+		}
+	}
+}
+
+func TestIsValidTenantStatus(t *testing.T) {
+	for _, status := range []string{
+		TenantStatusProvisioning,
+		TenantStatusActive,
+		TenantStatusPaused,
+		TenantStatusSuspended,
+		TenantStatusDeleting,
+		TenantStatusFailed,
+	} {
+		if !IsValidTenantStatus(status) {
+			t.Errorf("IsValidTenantStatus(%q) = false", status)
+		}
+	}
+
+	if IsValidTenantStatus("Active") || IsValidTenantStatus("") {
+		t.Error("IsValidTenantStatus accepted an unknown status")
+	}
+}
+
+func TestIsConsumptionStopped(t *testing.T) {
+	if !IsConsumptionStopped(TenantStatusPaused) || !IsConsumptionStopped(TenantStatusSuspended) {
+		t.Error("paused and suspended tenants must not consume")
+	}
+
+	// Deleting keeps consuming so the queue can drain
+	for _, status := range []string{TenantStatusProvisioning, TenantStatusActive, TenantStatusDeleting, TenantStatusFailed} {
+		if IsConsumptionStopped(status) {
+			t.Errorf("IsConsumptionStopped(%q) = true", status)
+		}
+	}
+}
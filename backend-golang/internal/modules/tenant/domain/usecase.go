@@ -2,7 +2,8 @@ package domain
 
 import (
 	"context"
-	
+	"time"
+
 	"github.com/streadway/amqp"
 )
 
@@ -12,6 +13,16 @@ type TenantManager interface {
 	Stop(ctx context.Context) error
 	StartConsumer(ctx context.Context, tenantID string) error
 	StopConsumer(ctx context.Context, tenantID string) error
+	Provision(ctx context.Context, tenantID string) error
+	Reconcile(ctx context.Context) *ReconcileReport
+	LastReconcileReport() *ReconcileReport
+	ScaleWorkers(ctx context.Context, tenantID string, workers int) error
+	SetPrefetch(ctx context.Context, tenantID string, prefetch int) error
+	SetDedup(ctx context.Context, tenantID string, enabled bool) error
+	SetThroughput(ctx context.Context, tenantID string, rate float64, burst int) error
+	PauseConsumer(ctx context.Context, tenantID string) error
+	ResumeConsumer(ctx context.Context, tenantID string) error
+	DecommissionTenant(ctx context.Context, tenantID string, ifEmpty bool) error
 	GetConsumer(tenantID string) *TenantConsumer
 	GetAllConsumers() []*TenantConsumer
 	GetActiveConsumers() map[string]*TenantConsumer
@@ -20,6 +31,8 @@ type TenantManager interface {
 	UpdateHeartbeat(tenantID string)
 	DebugRabbitMQState(ctx context.Context, tenantID string)
 	GetChannel() (*amqp.Channel, error)
+	Publish(ctx context.Context, tenantID string, msg amqp.Publishing) error
+	PublishBatch(ctx context.Context, tenantID string, msgs []amqp.Publishing) []error
 }
 
 // TenantUseCase interface untuk business logic tenant
@@ -27,12 +40,23 @@ type TenantUseCase interface {
 	Create(ctx context.Context, tenant *Tenant) error
 	GetByID(ctx context.Context, id string) (*Tenant, error)
 	Update(ctx context.Context, tenant *Tenant) error
+	Transition(ctx context.Context, id, status string) (*Tenant, error)
 	Delete(ctx context.Context, id string) error
+	DeleteWithDrain(ctx context.Context, id string, timeout time.Duration) error
+	EnsurePublishable(ctx context.Context, id string) error
 	List(ctx context.Context) ([]*Tenant, error)
 	StartConsumer(ctx context.Context, tenantID string) error
 	StopConsumer(ctx context.Context, tenantID string) error
 	GetConsumers(ctx context.Context) ([]*TenantConsumer, error)
 	GetConsumer(tenantID string) *TenantConsumer
+	LastReconcileReport() *ReconcileReport
 	UpdateConcurrency(ctx context.Context, id string, config *ConcurrencyConfig) error
+	UpdateDedup(ctx context.Context, id string, config *DedupConfig) error
+	UpdatePublishLimit(ctx context.Context, id string, config *PublishLimitConfig) error
+	UpdateThroughput(ctx context.Context, id string, config *ThroughputConfig) error
+	Pause(ctx context.Context, id string) error
+	Resume(ctx context.Context, id string) error
 	GetChannel() (*amqp.Channel, error)
+	Publish(ctx context.Context, tenantID string, msg amqp.Publishing) error
+	PublishBatch(ctx context.Context, tenantID string, msgs []amqp.Publishing) []error
 }
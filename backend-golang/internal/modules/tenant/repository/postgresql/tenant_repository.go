@@ -12,6 +12,10 @@ import (
 	"github.com/jatis/sample-stack-golang/internal/modules/tenant/domain"
 )
 
+// provisioningGracePeriod menunda pengambilan step pending oleh reconciler agar tidak
+// berjalan bersamaan dengan provisioning yang dijalankan langsung setelah tenant dibuat
+const provisioningGracePeriod = 30 * time.Second
+
 // TenantRepository implements domain.TenantRepository
 type TenantRepository struct {
 	db       *pgxpool.Pool
@@ -41,6 +45,14 @@ func (r *TenantRepository) Create(ctx context.Context, tenant *domain.Tenant) er
 		}
 	}
 
+	// Set default prefetch if not specified
+	if tenant.Prefetch <= 0 {
+		tenant.Prefetch = r.config.App.Prefetch
+		if tenant.Prefetch <= 0 {
+			tenant.Prefetch = 10 // Fallback default prefetch
+		}
+	}
+
 	// Start transaction
 	tx, err := r.db.Begin(ctx)
 	if err != nil {
@@ -50,8 +62,8 @@ func (r *TenantRepository) Create(ctx context.Context, tenant *domain.Tenant) er
 
 	// Insert tenant
 	query := `
-		INSERT INTO tenants (id, name, description, status, workers, created_at, updated_at)
-		VALUES ($1, $2, $3, $4, $5, $6, $7)`
+		INSERT INTO tenants (id, name, description, status, workers, prefetch, dedup_enabled, publish_rate, publish_burst, throughput_rate, throughput_burst, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)`
 
 	_, err = tx.Exec(ctx, query,
 		tenant.ID,
@@ -59,6 +71,12 @@ func (r *TenantRepository) Create(ctx context.Context, tenant *domain.Tenant) er
 		tenant.Description,
 		tenant.Status,
 		tenant.Workers,
+		tenant.Prefetch,
+		tenant.DedupEnabled,
+		tenant.PublishRate,
+		tenant.PublishBurst,
+		tenant.ThroughputRate,
+		tenant.ThroughputBurst,
 		time.Now(),
 		time.Now(),
 	)
@@ -73,6 +91,25 @@ func (r *TenantRepository) Create(ctx context.Context, tenant *domain.Tenant) er
 		return fmt.Errorf("failed to create messages partition: %w", err)
 	}
 
+	// Record provisioning steps in the outbox; the tenant row and partition are
+	// committed with this transaction, the broker steps are still pending
+	now := time.Now()
+	for i, step := range domain.ProvisioningSteps {
+		state := domain.ProvisioningStatePending
+		if step == domain.ProvisioningStepDBRow || step == domain.ProvisioningStepPartition {
+			state = domain.ProvisioningStateDone
+		}
+
+		_, err = tx.Exec(ctx, `
+			INSERT INTO tenant_provisioning (tenant_id, step, position, state, next_attempt_at, updated_at)
+			VALUES ($1, $2, $3, $4, $5, $6)`,
+			tenant.ID, step, i, state, now.Add(provisioningGracePeriod), now,
+		)
+		if err != nil {
+			return fmt.Errorf("failed to record provisioning step %s: %w", step, err)
+		}
+	}
+
 	// Commit transaction
 	if err = tx.Commit(ctx); err != nil {
 		return fmt.Errorf("failed to commit transaction: %w", err)
@@ -84,7 +121,7 @@ func (r *TenantRepository) Create(ctx context.Context, tenant *domain.Tenant) er
 // GetByID gets a tenant by ID
 func (r *TenantRepository) GetByID(ctx context.Context, id string) (*domain.Tenant, error) {
 	query := `
-		SELECT id, name, description, status, workers, created_at, updated_at
+		SELECT id, name, description, status, status_reason, workers, prefetch, dedup_enabled, publish_rate, publish_burst, throughput_rate, throughput_burst, created_at, updated_at
 		FROM tenants
 		WHERE id = $1`
 
@@ -94,7 +131,14 @@ func (r *TenantRepository) GetByID(ctx context.Context, id string) (*domain.Tena
 		&tenant.Name,
 		&tenant.Description,
 		&tenant.Status,
+		&tenant.StatusReason,
 		&tenant.Workers,
+		&tenant.Prefetch,
+		&tenant.DedupEnabled,
+		&tenant.PublishRate,
+		&tenant.PublishBurst,
+		&tenant.ThroughputRate,
+		&tenant.ThroughputBurst,
 		&tenant.CreatedAt,
 		&tenant.UpdatedAt,
 	)
@@ -109,17 +153,17 @@ func (r *TenantRepository) GetByID(ctx context.Context, id string) (*domain.Tena
 	return &tenant, nil
 }
 
-// Update updates a tenant
+// Update updates the name and description of a tenant. The status is changed
+// through UpdateStatus so that transitions are validated by the usecase.
 func (r *TenantRepository) Update(ctx context.Context, tenant *domain.Tenant) error {
 	query := `
 		UPDATE tenants
-		SET name = $1, description = $2, status = $3, updated_at = $4
-		WHERE id = $5`
+		SET name = $1, description = $2, updated_at = $3
+		WHERE id = $4`
 
 	result, err := r.db.Exec(ctx, query,
 		tenant.Name,
 		tenant.Description,
-		tenant.Status,
 		time.Now(),
 		tenant.ID,
 	)
@@ -172,7 +216,7 @@ func (r *TenantRepository) Delete(ctx context.Context, id string) error {
 // List lists all tenants
 func (r *TenantRepository) List(ctx context.Context) ([]*domain.Tenant, error) {
 	query := `
-		SELECT id, name, description, status, workers, created_at, updated_at
+		SELECT id, name, description, status, status_reason, workers, prefetch, dedup_enabled, publish_rate, publish_burst, throughput_rate, throughput_burst, created_at, updated_at
 		FROM tenants
 		ORDER BY id`
 
@@ -190,7 +234,14 @@ func (r *TenantRepository) List(ctx context.Context) ([]*domain.Tenant, error) {
 			&tenant.Name,
 			&tenant.Description,
 			&tenant.Status,
+			&tenant.StatusReason,
 			&tenant.Workers,
+			&tenant.Prefetch,
+			&tenant.DedupEnabled,
+			&tenant.PublishRate,
+			&tenant.PublishBurst,
+			&tenant.ThroughputRate,
+			&tenant.ThroughputBurst,
 			&tenant.CreatedAt,
 			&tenant.UpdatedAt,
 		)
@@ -208,13 +259,13 @@ func (r *TenantRepository) List(ctx context.Context) ([]*domain.Tenant, error) {
 }
 
 // UpdateConcurrency updates the concurrency configuration for a tenant
-func (r *TenantRepository) UpdateConcurrency(ctx context.Context, id string, workers int) error {
+func (r *TenantRepository) UpdateConcurrency(ctx context.Context, id string, workers, prefetch int) error {
 	query := `
 		UPDATE tenants
-		SET workers = $1, updated_at = $2
-		WHERE id = $3`
+		SET workers = $1, prefetch = $2, updated_at = $3
+		WHERE id = $4`
 
-	result, err := r.db.Exec(ctx, query, workers, time.Now(), id)
+	result, err := r.db.Exec(ctx, query, workers, prefetch, time.Now(), id)
 	if err != nil {
 		return fmt.Errorf("failed to update tenant concurrency: %w", err)
 	}
@@ -224,4 +275,195 @@ func (r *TenantRepository) UpdateConcurrency(ctx context.Context, id string, wor
 	}
 
 	return nil
-}
\ No newline at end of file
+}
+
+// UpdateDedup enables or disables consumer-side deduplication for a tenant
+func (r *TenantRepository) UpdateDedup(ctx context.Context, id string, enabled bool) error {
+	query := `
+		UPDATE tenants
+		SET dedup_enabled = $1, updated_at = $2
+		WHERE id = $3`
+
+	result, err := r.db.Exec(ctx, query, enabled, time.Now(), id)
+	if err != nil {
+		return fmt.Errorf("failed to update tenant dedup: %w", err)
+	}
+
+	if result.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
+
+	return nil
+}
+
+// UpdatePublishLimit updates the publish rate limit of a tenant
+func (r *TenantRepository) UpdatePublishLimit(ctx context.Context, id string, rate float64, burst int) error {
+	query := `
+		UPDATE tenants
+		SET publish_rate = $1, publish_burst = $2, updated_at = $3
+		WHERE id = $4`
+
+	result, err := r.db.Exec(ctx, query, rate, burst, time.Now(), id)
+	if err != nil {
+		return fmt.Errorf("failed to update tenant publish limit: %w", err)
+	}
+
+	if result.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
+
+	return nil
+}
+
+// UpdateThroughput updates the consumption rate limit of a tenant
+func (r *TenantRepository) UpdateThroughput(ctx context.Context, id string, rate float64, burst int) error {
+	query := `
+		UPDATE tenants
+		SET throughput_rate = $1, throughput_burst = $2, updated_at = $3
+		WHERE id = $4`
+
+	result, err := r.db.Exec(ctx, query, rate, burst, time.Now(), id)
+	if err != nil {
+		return fmt.Errorf("failed to update tenant throughput: %w", err)
+	}
+
+	if result.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
+
+	return nil
+}
+
+// UpdateStatus updates the status of a tenant and clears its status reason
+func (r *TenantRepository) UpdateStatus(ctx context.Context, id string, status string) error {
+	query := `
+		UPDATE tenants
+		SET status = $1, status_reason = '', updated_at = $2
+		WHERE id = $3`
+
+	result, err := r.db.Exec(ctx, query, status, time.Now(), id)
+	if err != nil {
+		return fmt.Errorf("failed to update tenant status: %w", err)
+	}
+
+	if result.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
+
+	return nil
+}
+
+// GetProvisioning returns the provisioning steps of a tenant in execution order
+func (r *TenantRepository) GetProvisioning(ctx context.Context, id string) ([]*domain.ProvisioningStep, error) {
+	query := `
+		SELECT step, state, attempts, last_error, next_attempt_at, updated_at
+		FROM tenant_provisioning
+		WHERE tenant_id = $1
+		ORDER BY position`
+
+	rows, err := r.db.Query(ctx, query, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get tenant provisioning: %w", err)
+	}
+	defer rows.Close()
+
+	var steps []*domain.ProvisioningStep
+	for rows.Next() {
+		var step domain.ProvisioningStep
+		if err := rows.Scan(
+			&step.Step,
+			&step.State,
+			&step.Attempts,
+			&step.LastError,
+			&step.NextAttemptAt,
+			&step.UpdatedAt,
+		); err != nil {
+			return nil, fmt.Errorf("failed to scan provisioning step: %w", err)
+		}
+		steps = append(steps, &step)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating provisioning rows: %w", err)
+	}
+
+	return steps, nil
+}
+
+// StartDrain marks a tenant as deleting and records the drain in the same transaction,
+// so the drain survives restarts and can be driven by any replica. It returns
+// pgx.ErrNoRows if the tenant no longer has the expected previous status.
+func (r *TenantRepository) StartDrain(ctx context.Context, id, previousStatus string, deadline time.Time) error {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	now := time.Now()
+	result, err := tx.Exec(ctx, `
+		UPDATE tenants
+		SET status = $1, status_reason = '', updated_at = $2
+		WHERE id = $3 AND status = $4`,
+		domain.TenantStatusDeleting, now, id, previousStatus,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to mark tenant as deleting: %w", err)
+	}
+
+	if result.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
+
+	// Drain sebelumnya yang dibatalkan ditimpa
+	_, err = tx.Exec(ctx, `
+		INSERT INTO tenant_drains (tenant_id, previous_status, state, pending_messages, empty_checks, started_at, deadline, finished_at, error, updated_at)
+		VALUES ($1, $2, $3, 0, 0, $4, $5, NULL, '', $4)
+		ON CONFLICT (tenant_id) DO UPDATE
+		SET previous_status = EXCLUDED.previous_status, state = EXCLUDED.state,
+			pending_messages = 0, empty_checks = 0, started_at = EXCLUDED.started_at,
+			deadline = EXCLUDED.deadline, finished_at = NULL, error = '', updated_at = EXCLUDED.updated_at`,
+		id, previousStatus, domain.DrainStateDraining, now, deadline,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to record tenant drain: %w", err)
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+// GetDrain returns the drain-then-delete progress of a tenant, or nil if none was started
+func (r *TenantRepository) GetDrain(ctx context.Context, id string) (*domain.DeletionProgress, error) {
+	var progress domain.DeletionProgress
+	var finishedAt *time.Time
+	err := r.db.QueryRow(ctx, `
+		SELECT state, previous_status, pending_messages, started_at, deadline, finished_at, error
+		FROM tenant_drains
+		WHERE tenant_id = $1`,
+		id,
+	).Scan(
+		&progress.State,
+		&progress.PreviousStatus,
+		&progress.PendingMessages,
+		&progress.StartedAt,
+		&progress.Deadline,
+		&finishedAt,
+		&progress.Error,
+	)
+	if err == pgx.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get tenant drain: %w", err)
+	}
+
+	if finishedAt != nil {
+		progress.FinishedAt = *finishedAt
+	}
+
+	return &progress, nil
+}
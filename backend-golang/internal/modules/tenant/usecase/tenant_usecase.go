@@ -4,15 +4,20 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"time"
 
+	"github.com/jackc/pgx/v5"
 	"github.com/jatis/sample-stack-golang/internal/modules/tenant/domain"
 	"github.com/jatis/sample-stack-golang/pkg/logger"
 	"github.com/streadway/amqp"
 )
 
 var (
-	ErrTenantNotFound = errors.New("tenant not found")
-	ErrInvalidInput   = errors.New("invalid input")
+	ErrTenantNotFound    = errors.New("tenant not found")
+	ErrInvalidInput      = errors.New("invalid input")
+	ErrTenantDeleting    = errors.New("tenant is being deleted")
+	ErrTenantSuspended   = errors.New("tenant is suspended")
+	ErrInvalidTransition = errors.New("invalid tenant status transition")
 )
 
 // TenantUseCase implements domain.TenantUseCase
@@ -29,18 +34,32 @@ func NewTenantUseCase(repo domain.TenantRepository, manager domain.TenantManager
 	}
 }
 
-// Create creates a new tenant
+// Create creates a new tenant. The tenant row, its partition and the provisioning
+// steps are committed together; the broker resources and the consumer are then
+// provisioned right away. If a step fails the tenant is marked failed with the
+// reason and the provisioning reconciler keeps retrying until it becomes active.
 func (u *TenantUseCase) Create(ctx context.Context, tenant *domain.Tenant) error {
+	tenant.Status = domain.TenantStatusProvisioning
 	if err := u.repo.Create(ctx, tenant); err != nil {
 		return fmt.Errorf("failed to create tenant: %v", err)
 	}
 
-	// Start consumer untuk tenant baru
-	if err := u.manager.StartConsumer(ctx, tenant.ID); err != nil {
-		// Log error tapi jangan return error karena tenant sudah dibuat
-		fmt.Printf("Warning: failed to start consumer for tenant %s: %v\n", tenant.ID, err)
+	if err := u.manager.Provision(ctx, tenant.ID); err != nil {
+		// Jangan return error karena tenant sudah dibuat; reconciler akan mencoba ulang
+		logger.Log.WithFields(map[string]interface{}{
+			"tenant_id": tenant.ID,
+			"error":     err,
+		}).Warn("Failed to provision new tenant, provisioning will be retried")
 	}
 
+	created, err := u.GetByID(ctx, tenant.ID)
+	if err != nil {
+		return err
+	}
+	tenant.Status = created.Status
+	tenant.StatusReason = created.StatusReason
+	tenant.Provisioning = created.Provisioning
+
 	return nil
 }
 
@@ -48,22 +67,148 @@ func (u *TenantUseCase) Create(ctx context.Context, tenant *domain.Tenant) error
 func (u *TenantUseCase) GetByID(ctx context.Context, id string) (*domain.Tenant, error) {
 	tenant, err := u.repo.GetByID(ctx, id)
 	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrTenantNotFound
+		}
 		return nil, fmt.Errorf("failed to get tenant: %v", err)
 	}
 	if tenant == nil {
 		return nil, ErrTenantNotFound
 	}
+
+	// Attach the provisioning outbox while the tenant is not fully provisioned
+	if tenant.Status == domain.TenantStatusProvisioning || tenant.Status == domain.TenantStatusFailed {
+		steps, err := u.repo.GetProvisioning(ctx, id)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get tenant provisioning: %v", err)
+		}
+		tenant.Provisioning = steps
+	}
+
+	// Attach drain-then-delete progress if a deletion is running or was aborted
+	deletion, err := u.repo.GetDrain(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get tenant drain: %v", err)
+	}
+	tenant.Deletion = deletion
+
 	return tenant, nil
 }
 
-// Update updates a tenant
+// Update updates a tenant. A changed status is applied as a validated transition.
 func (u *TenantUseCase) Update(ctx context.Context, tenant *domain.Tenant) error {
+	current, err := u.GetByID(ctx, tenant.ID)
+	if err != nil {
+		return err
+	}
+
+	if tenant.Status != "" && tenant.Status != current.Status {
+		if _, err := u.Transition(ctx, tenant.ID, tenant.Status); err != nil {
+			return err
+		}
+	} else {
+		tenant.Status = current.Status
+	}
+
 	if err := u.repo.Update(ctx, tenant); err != nil {
 		return fmt.Errorf("failed to update tenant: %v", err)
 	}
 	return nil
 }
 
+// Transition moves a tenant to a new status and applies its side effects on the
+// consumer: active starts or resumes consumption, paused and suspended stop it.
+// Deleting is only entered through Delete/DeleteWithDrain, and provisioning and
+// failed are set by the system. Invalid transitions return ErrInvalidTransition.
+func (u *TenantUseCase) Transition(ctx context.Context, id, status string) (*domain.Tenant, error) {
+	if !domain.IsValidTenantStatus(status) {
+		return nil, fmt.Errorf("%w: unknown status %q", ErrInvalidInput, status)
+	}
+
+	tenant, err := u.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	switch status {
+	case domain.TenantStatusActive, domain.TenantStatusPaused, domain.TenantStatusSuspended:
+	default:
+		return nil, fmt.Errorf("%w: status %q cannot be set directly", ErrInvalidTransition, status)
+	}
+
+	if tenant.Status == domain.TenantStatusDeleting {
+		return nil, ErrTenantDeleting
+	}
+	if tenant.Status != status && !domain.CanTransitionTenantStatus(tenant.Status, status) {
+		return nil, fmt.Errorf("%w: %s -> %s", ErrInvalidTransition, tenant.Status, status)
+	}
+
+	previousStatus := tenant.Status
+
+	// Tenant yang gagal diprovision diaktifkan dengan menjalankan ulang step yang belum selesai;
+	// jika gagal lagi, status tetap failed dengan alasan terbaru
+	if previousStatus == domain.TenantStatusFailed && status == domain.TenantStatusActive {
+		if err := u.manager.Provision(ctx, id); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := u.repo.UpdateStatus(ctx, id, status); err != nil {
+		return nil, fmt.Errorf("failed to update tenant status: %v", err)
+	}
+
+	if err := u.applyStatus(ctx, id, status); err != nil {
+		// Consumer yang gagal diaktifkan ditandai failed; selain itu kembalikan status sebelumnya
+		restore := previousStatus
+		if status == domain.TenantStatusActive {
+			restore = domain.TenantStatusFailed
+		}
+		if restoreErr := u.repo.UpdateStatus(context.Background(), id, restore); restoreErr != nil {
+			logger.Log.WithFields(map[string]interface{}{
+				"tenant_id": id,
+				"error":     restoreErr,
+			}).Error("Failed to restore tenant status after failed transition")
+		}
+		return nil, err
+	}
+
+	logger.Log.WithFields(map[string]interface{}{
+		"tenant_id": id,
+		"from":      previousStatus,
+		"to":        status,
+	}).Info("Tenant status changed")
+
+	tenant.Status = status
+	return tenant, nil
+}
+
+// applyStatus brings the tenant consumer in line with a tenant status
+func (u *TenantUseCase) applyStatus(ctx context.Context, id, status string) error {
+	consumer := u.manager.GetConsumer(id)
+
+	switch status {
+	case domain.TenantStatusActive:
+		// Start the consumer if it is not running, e.g. it failed to start earlier
+		if consumer == nil {
+			if err := u.manager.StartConsumer(ctx, id); err != nil {
+				return fmt.Errorf("failed to start consumer: %v", err)
+			}
+			return nil
+		}
+		if err := u.manager.ResumeConsumer(ctx, id); err != nil {
+			return fmt.Errorf("failed to resume consumer: %v", err)
+		}
+	case domain.TenantStatusPaused, domain.TenantStatusSuspended:
+		if consumer != nil {
+			if err := u.manager.PauseConsumer(ctx, id); err != nil {
+				return fmt.Errorf("failed to pause consumer: %v", err)
+			}
+		}
+	}
+
+	return nil
+}
+
 // Delete deletes a tenant
 func (u *TenantUseCase) Delete(ctx context.Context, id string) error {
 	// Check if tenant exists before proceeding
@@ -83,17 +228,17 @@ func (u *TenantUseCase) Delete(ctx context.Context, id string) error {
 		u.manager.DebugRabbitMQState(ctx, id)
 	}
 	
-	// Try to stop the consumer
+	// Stop the consumer and delete the tenant queues, DLQ and retry queues
 	if u.manager != nil {
-		err := u.manager.StopConsumer(ctx, id)
+		err := u.manager.DecommissionTenant(ctx, id, false)
 		if err != nil {
 			logger.Log.WithFields(map[string]interface{}{
 				"tenant_id": id,
 				"error":     err,
-			}).Warn("Failed to stop consumer, continuing with tenant deletion")
+			}).Warn("Failed to decommission tenant queues, continuing with tenant deletion")
 			// Don't return error here, continue with deletion
 		} else {
-			logger.Log.WithField("tenant_id", id).Info("Successfully stopped consumer")
+			logger.Log.WithField("tenant_id", id).Info("Successfully stopped consumer and deleted tenant queues")
 		}
 	}
 
@@ -110,6 +255,66 @@ func (u *TenantUseCase) Delete(ctx context.Context, id string) error {
 	return nil
 }
 
+// DeleteWithDrain marks a tenant as deleting and records the drain in the database;
+// the tenant manager removes the tenant once its backlog has been processed. New
+// publishes are refused while the tenant is deleting, and a paused or suspended
+// tenant consumes again so its backlog can drain. If the backlog is not drained
+// before the timeout, the deletion is aborted and the previous status is restored.
+func (u *TenantUseCase) DeleteWithDrain(ctx context.Context, id string, timeout time.Duration) error {
+	if timeout <= 0 {
+		return ErrInvalidInput
+	}
+
+	tenant, err := u.GetByID(ctx, id)
+	if err != nil {
+		return err
+	}
+	if tenant.Status == domain.TenantStatusDeleting {
+		return ErrTenantDeleting
+	}
+	if !domain.CanTransitionTenantStatus(tenant.Status, domain.TenantStatusDeleting) {
+		return fmt.Errorf("%w: %s -> %s", ErrInvalidTransition, tenant.Status, domain.TenantStatusDeleting)
+	}
+
+	if err := u.repo.StartDrain(ctx, id, tenant.Status, time.Now().Add(timeout)); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			// Status tenant berubah sejak dibaca di atas
+			return fmt.Errorf("%w: tenant status changed concurrently", ErrInvalidTransition)
+		}
+		return fmt.Errorf("failed to mark tenant as deleting: %v", err)
+	}
+
+	logger.Log.WithFields(map[string]interface{}{
+		"tenant_id": id,
+		"timeout":   timeout,
+	}).Info("Draining tenant before deletion")
+
+	return nil
+}
+
+// EnsurePublishable returns an error if new messages must not be published for the tenant
+func (u *TenantUseCase) EnsurePublishable(ctx context.Context, id string) error {
+	// Dipanggil pada setiap publish; hanya status yang dibutuhkan sehingga progress
+	// drain dan provisioning tidak ikut dibaca
+	tenant, err := u.repo.GetByID(ctx, id)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return ErrTenantNotFound
+		}
+		return fmt.Errorf("failed to get tenant: %v", err)
+	}
+	if tenant == nil {
+		return ErrTenantNotFound
+	}
+	switch tenant.Status {
+	case domain.TenantStatusDeleting:
+		return ErrTenantDeleting
+	case domain.TenantStatusSuspended:
+		return ErrTenantSuspended
+	}
+	return nil
+}
+
 // List lists all tenants
 func (u *TenantUseCase) List(ctx context.Context) ([]*domain.Tenant, error) {
 	tenants, err := u.repo.List(ctx)
@@ -147,18 +352,23 @@ func (u *TenantUseCase) GetConsumers(ctx context.Context) ([]*domain.TenantConsu
 // UpdateConcurrency updates the concurrency configuration for a tenant
 func (u *TenantUseCase) UpdateConcurrency(ctx context.Context, id string, config *domain.ConcurrencyConfig) error {
 	// Validate input
-	if config == nil || config.Workers <= 0 {
+	if config == nil || config.Workers <= 0 || config.Prefetch < 0 {
 		return ErrInvalidInput
 	}
 
 	// Check if tenant exists
-	_, err := u.GetByID(ctx, id)
+	tenant, err := u.GetByID(ctx, id)
 	if err != nil {
 		return err
 	}
 
+	// Prefetch 0 berarti prefetch tenant tidak diubah
+	if config.Prefetch == 0 {
+		config.Prefetch = tenant.Prefetch
+	}
+
 	// Update concurrency in database
-	if err := u.repo.UpdateConcurrency(ctx, id, config.Workers); err != nil {
+	if err := u.repo.UpdateConcurrency(ctx, id, config.Workers, config.Prefetch); err != nil {
 		return fmt.Errorf("failed to update concurrency: %v", err)
 	}
 
@@ -169,23 +379,119 @@ func (u *TenantUseCase) UpdateConcurrency(ctx context.Context, id string, config
 	
 	consumer := u.manager.GetConsumer(id)
 	if consumer != nil {
-		// Restart consumer with new worker count
-		if err := u.StopConsumer(ctx, id); err != nil {
-			logger.Log.WithFields(map[string]interface{}{
-				"tenant_id": id,
-				"error":     err,
-			}).Warn("Failed to stop consumer during concurrency update, continuing")
+		// Scale worker pool in place so pending messages in the queue are kept
+		if err := u.manager.ScaleWorkers(ctx, id, config.Workers); err != nil {
+			return fmt.Errorf("failed to scale consumer workers: %v", err)
+		}
+		if config.Prefetch > 0 {
+			if err := u.manager.SetPrefetch(ctx, id, config.Prefetch); err != nil {
+				return fmt.Errorf("failed to update consumer prefetch: %v", err)
+			}
 		}
+	}
+
+	return nil
+}
+
+// UpdateDedup enables or disables consumer-side deduplication for a tenant
+func (u *TenantUseCase) UpdateDedup(ctx context.Context, id string, config *domain.DedupConfig) error {
+	if config == nil {
+		return ErrInvalidInput
+	}
+
+	// Check if tenant exists
+	if _, err := u.GetByID(ctx, id); err != nil {
+		return err
+	}
+
+	if err := u.repo.UpdateDedup(ctx, id, config.Enabled); err != nil {
+		return fmt.Errorf("failed to update dedup: %v", err)
+	}
 
-		// Start consumer with new worker count
-		if err := u.StartConsumer(ctx, id); err != nil {
-			return fmt.Errorf("failed to restart consumer with new concurrency: %v", err)
+	if u.manager == nil {
+		return fmt.Errorf("tenant manager not initialized")
+	}
+
+	// Apply to the running consumer so the change takes effect without a restart
+	if consumer := u.manager.GetConsumer(id); consumer != nil {
+		if err := u.manager.SetDedup(ctx, id, config.Enabled); err != nil {
+			return fmt.Errorf("failed to update consumer dedup: %v", err)
 		}
 	}
 
 	return nil
 }
 
+// UpdatePublishLimit updates the publish rate limit of a tenant. Rate limits are
+// read per request by the rate limit middleware, so no consumer change is needed.
+func (u *TenantUseCase) UpdatePublishLimit(ctx context.Context, id string, config *domain.PublishLimitConfig) error {
+	if config == nil || config.Rate < 0 || config.Burst < 0 {
+		return ErrInvalidInput
+	}
+
+	// Check if tenant exists
+	if _, err := u.GetByID(ctx, id); err != nil {
+		return err
+	}
+
+	if err := u.repo.UpdatePublishLimit(ctx, id, config.Rate, config.Burst); err != nil {
+		return fmt.Errorf("failed to update publish limit: %v", err)
+	}
+
+	return nil
+}
+
+// UpdateThroughput updates the consumption rate limit of a tenant and applies it
+// to the running consumer
+func (u *TenantUseCase) UpdateThroughput(ctx context.Context, id string, config *domain.ThroughputConfig) error {
+	if config == nil || config.Rate < 0 || config.Burst < 0 {
+		return ErrInvalidInput
+	}
+
+	// Check if tenant exists
+	if _, err := u.GetByID(ctx, id); err != nil {
+		return err
+	}
+
+	if err := u.repo.UpdateThroughput(ctx, id, config.Rate, config.Burst); err != nil {
+		return fmt.Errorf("failed to update throughput: %v", err)
+	}
+
+	if u.manager == nil {
+		return fmt.Errorf("tenant manager not initialized")
+	}
+
+	if consumer := u.manager.GetConsumer(id); consumer != nil {
+		if err := u.manager.SetThroughput(ctx, id, config.Rate, config.Burst); err != nil {
+			return fmt.Errorf("failed to update consumer throughput: %v", err)
+		}
+	}
+
+	return nil
+}
+
+// Pause stops consumption for a tenant while its queue keeps accumulating messages.
+// The paused status is persisted so the consumer stays paused after a restart.
+func (u *TenantUseCase) Pause(ctx context.Context, id string) error {
+	_, err := u.Transition(ctx, id, domain.TenantStatusPaused)
+	return err
+}
+
+// Resume restarts consumption for a paused tenant
+func (u *TenantUseCase) Resume(ctx context.Context, id string) error {
+	tenant, err := u.GetByID(ctx, id)
+	if err != nil {
+		return err
+	}
+	// A suspended tenant must be activated explicitly through its status
+	if tenant.Status == domain.TenantStatusSuspended {
+		return fmt.Errorf("%w: tenant is suspended, set its status to active instead", ErrInvalidTransition)
+	}
+
+	_, err = u.Transition(ctx, id, domain.TenantStatusActive)
+	return err
+}
+
 // stopConsumer is a helper method to stop a consumer
 func (u *TenantUseCase) stopConsumer(consumer *domain.TenantConsumer) error {
 	if consumer != nil && consumer.StopChannel != nil {
@@ -202,10 +508,40 @@ func (u *TenantUseCase) GetChannel() (*amqp.Channel, error) {
 	return u.manager.GetChannel()
 }
 
+// Publish publishes a message to the tenant queue and waits for the broker confirm
+func (u *TenantUseCase) Publish(ctx context.Context, tenantID string, msg amqp.Publishing) error {
+	if u.manager == nil {
+		return fmt.Errorf("tenant manager not initialized")
+	}
+	return u.manager.Publish(ctx, tenantID, msg)
+}
+
+// PublishBatch publishes messages to the tenant queue on a single confirm-mode channel
+// and returns the result for each message
+func (u *TenantUseCase) PublishBatch(ctx context.Context, tenantID string, msgs []amqp.Publishing) []error {
+	if u.manager == nil {
+		errs := make([]error, len(msgs))
+		for i := range errs {
+			errs[i] = fmt.Errorf("tenant manager not initialized")
+		}
+		return errs
+	}
+	return u.manager.PublishBatch(ctx, tenantID, msgs)
+}
+
 // GetConsumer gets a consumer for a tenant
 func (u *TenantUseCase) GetConsumer(tenantID string) *domain.TenantConsumer {
 	if u.manager == nil {
 		return nil
 	}
 	return u.manager.GetConsumer(tenantID)
-}
\ No newline at end of file
+}
+
+// LastReconcileReport returns the result of the last reconciliation between the
+// tenants table, the running consumers and the broker queues
+func (u *TenantUseCase) LastReconcileReport() *domain.ReconcileReport {
+	if u.manager == nil {
+		return nil
+	}
+	return u.manager.LastReconcileReport()
+}
@@ -0,0 +1,156 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/jatis/sample-stack-golang/internal/modules/tenant/domain"
+	"github.com/jatis/sample-stack-golang/pkg/logger"
+)
+
+func TestMain(m *testing.M) {
+	logger.Log = logrus.New()
+	logger.Log.SetOutput(io.Discard)
+	os.Exit(m.Run())
+}
+
+// fakeTenantRepository menyimpan tenant di memory. Method yang tidak dipakai test
+// tidak diimplementasikan sehingga pemanggilan yang tidak diharapkan langsung panic.
+type fakeTenantRepository struct {
+	domain.TenantRepository
+
+	tenants map[string]*domain.Tenant
+	drains  map[string]*domain.DeletionProgress
+}
+
+func newFakeTenantRepository(tenants ...*domain.Tenant) *fakeTenantRepository {
+	repo := &fakeTenantRepository{
+		tenants: make(map[string]*domain.Tenant),
+		drains:  make(map[string]*domain.DeletionProgress),
+	}
+	for _, tenant := range tenants {
+		repo.tenants[tenant.ID] = tenant
+	}
+	return repo
+}
+
+func (r *fakeTenantRepository) GetByID(ctx context.Context, id string) (*domain.Tenant, error) {
+	tenant, ok := r.tenants[id]
+	if !ok {
+		return nil, pgx.ErrNoRows
+	}
+	copied := *tenant
+	return &copied, nil
+}
+
+func (r *fakeTenantRepository) StartDrain(ctx context.Context, id, previousStatus string, deadline time.Time) error {
+	tenant, ok := r.tenants[id]
+	if !ok || tenant.Status != previousStatus {
+		return pgx.ErrNoRows
+	}
+	tenant.Status = domain.TenantStatusDeleting
+	r.drains[id] = &domain.DeletionProgress{
+		State:          domain.DrainStateDraining,
+		PreviousStatus: previousStatus,
+		StartedAt:      time.Now(),
+		Deadline:       deadline,
+	}
+	return nil
+}
+
+func (r *fakeTenantRepository) GetDrain(ctx context.Context, id string) (*domain.DeletionProgress, error) {
+	return r.drains[id], nil
+}
+
+func (r *fakeTenantRepository) GetProvisioning(ctx context.Context, id string) ([]*domain.ProvisioningStep, error) {
+	return nil, nil
+}
+
+// getDrainPanics gagal jika progress drain dibaca
+type getDrainPanics struct {
+	*fakeTenantRepository
+}
+
+func (r getDrainPanics) GetDrain(ctx context.Context, id string) (*domain.DeletionProgress, error) {
+	panic("EnsurePublishable must not read the drain progress")
+}
+
+func TestEnsurePublishable(t *testing.T) {
+	repo := newFakeTenantRepository(
+		&domain.Tenant{ID: "active", Status: domain.TenantStatusActive},
+		&domain.Tenant{ID: "paused", Status: domain.TenantStatusPaused},
+		&domain.Tenant{ID: "suspended", Status: domain.TenantStatusSuspended},
+		&domain.Tenant{ID: "deleting", Status: domain.TenantStatusDeleting},
+	)
+	u := NewTenantUseCase(getDrainPanics{repo}, nil)
+	ctx := context.Background()
+
+	assert.NoError(t, u.EnsurePublishable(ctx, "active"))
+	assert.NoError(t, u.EnsurePublishable(ctx, "paused"))
+	assert.ErrorIs(t, u.EnsurePublishable(ctx, "suspended"), ErrTenantSuspended)
+	assert.ErrorIs(t, u.EnsurePublishable(ctx, "deleting"), ErrTenantDeleting)
+	assert.ErrorIs(t, u.EnsurePublishable(ctx, "missing"), ErrTenantNotFound)
+}
+
+func TestDeleteWithDrain(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("records the previous status and deadline", func(t *testing.T) {
+		repo := newFakeTenantRepository(&domain.Tenant{ID: "t1", Status: domain.TenantStatusPaused})
+		u := NewTenantUseCase(repo, nil)
+
+		before := time.Now()
+		require.NoError(t, u.DeleteWithDrain(ctx, "t1", time.Minute))
+
+		tenant, err := u.GetByID(ctx, "t1")
+		require.NoError(t, err)
+		assert.Equal(t, domain.TenantStatusDeleting, tenant.Status)
+		require.NotNil(t, tenant.Deletion)
+		assert.Equal(t, domain.DrainStateDraining, tenant.Deletion.State)
+		assert.Equal(t, domain.TenantStatusPaused, tenant.Deletion.PreviousStatus)
+		assert.WithinDuration(t, before.Add(time.Minute), tenant.Deletion.Deadline, time.Second)
+	})
+
+	t.Run("rejects a tenant that is already deleting", func(t *testing.T) {
+		repo := newFakeTenantRepository(&domain.Tenant{ID: "t1", Status: domain.TenantStatusDeleting})
+		u := NewTenantUseCase(repo, nil)
+
+		assert.ErrorIs(t, u.DeleteWithDrain(ctx, "t1", time.Minute), ErrTenantDeleting)
+	})
+
+	t.Run("rejects a non-positive timeout", func(t *testing.T) {
+		repo := newFakeTenantRepository(&domain.Tenant{ID: "t1", Status: domain.TenantStatusActive})
+		u := NewTenantUseCase(repo, nil)
+
+		assert.ErrorIs(t, u.DeleteWithDrain(ctx, "t1", 0), ErrInvalidInput)
+		assert.Equal(t, domain.TenantStatusActive, repo.tenants["t1"].Status)
+	})
+
+	t.Run("status changed concurrently", func(t *testing.T) {
+		repo := &changingStatusRepository{newFakeTenantRepository(&domain.Tenant{ID: "t1", Status: domain.TenantStatusActive})}
+		u := NewTenantUseCase(repo, nil)
+
+		err := u.DeleteWithDrain(ctx, "t1", time.Minute)
+		assert.True(t, errors.Is(err, ErrInvalidTransition), "got %v", err)
+	})
+}
+
+// changingStatusRepository mensimulasikan status tenant yang diubah request lain
+// setelah dibaca oleh DeleteWithDrain
+type changingStatusRepository struct {
+	*fakeTenantRepository
+}
+
+func (r *changingStatusRepository) StartDrain(ctx context.Context, id, previousStatus string, deadline time.Time) error {
+	r.tenants[id].Status = domain.TenantStatusSuspended
+	return r.fakeTenantRepository.StartDrain(ctx, id, previousStatus, deadline)
+}
@@ -112,6 +112,24 @@ var (
 		},
 		[]string{"tenant_id"},
 	)
+
+	// Publish rate limit metrics
+	PublishRateLimited = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "tenant_publish_rate_limited_total",
+			Help: "The total number of publish requests rejected by the tenant rate limit",
+		},
+		[]string{"tenant_id"},
+	)
+
+	// RabbitMQ metrics - Deduplication metrics
+	MessageDuplicates = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "rabbitmq_messages_duplicate_total",
+			Help: "The total number of duplicate messages acked without processing",
+		},
+		[]string{"tenant_id"},
+	)
 )
 
 // SetupMetrics mengatur endpoint metrics dan middleware
@@ -186,4 +204,14 @@ func RecordMessageRetry(tenantID string) {
 // RecordMessageDeadLettered increments the counter for dead lettered messages
 func RecordMessageDeadLettered(tenantID string) {
 	MessageDeadLettered.WithLabelValues(tenantID).Inc()
-} 
\ No newline at end of file
+} 
+
+// RecordMessageDuplicate increments the counter for duplicate messages skipped by deduplication
+func RecordMessageDuplicate(tenantID string) {
+	MessageDuplicates.WithLabelValues(tenantID).Inc()
+}
+
+// RecordPublishRateLimited increments the counter for publish requests rejected by the rate limit
+func RecordPublishRateLimited(tenantID string) {
+	PublishRateLimited.WithLabelValues(tenantID).Inc()
+}
@@ -0,0 +1,107 @@
+package lease
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// DefaultTTL adalah lama lease berlaku jika TTL tidak dikonfigurasi
+const DefaultTTL = 30 * time.Second
+
+// renewScript memperpanjang lease hanya jika masih dimiliki owner yang sama
+var renewScript = redis.NewScript(`
+if redis.call('GET', KEYS[1]) == ARGV[1] then
+	return redis.call('PEXPIRE', KEYS[1], ARGV[2])
+end
+return 0
+`)
+
+// releaseScript menghapus lease hanya jika masih dimiliki owner yang sama
+var releaseScript = redis.NewScript(`
+if redis.call('GET', KEYS[1]) == ARGV[1] then
+	return redis.call('DEL', KEYS[1])
+end
+return 0
+`)
+
+// Locker adalah lease eksklusif berbasis Redis. Setiap lease dimiliki satu owner
+// (mis. ID replica) dan kedaluwarsa jika tidak diperpanjang dalam ttl, sehingga
+// owner lain dapat mengambil alih setelah owner sebelumnya mati.
+type Locker struct {
+	client *redis.Client
+	prefix string
+	owner  string
+	ttl    time.Duration
+}
+
+// NewLocker membuat Locker baru; prefix dipakai sebagai awalan key Redis.
+// ttl <= 0 memakai DefaultTTL.
+func NewLocker(client *redis.Client, prefix, owner string, ttl time.Duration) *Locker {
+	if ttl <= 0 {
+		ttl = DefaultTTL
+	}
+
+	return &Locker{
+		client: client,
+		prefix: prefix,
+		owner:  owner,
+		ttl:    ttl,
+	}
+}
+
+// Owner mengembalikan ID owner lease milik Locker ini
+func (l *Locker) Owner() string {
+	return l.owner
+}
+
+// TTL mengembalikan lama lease berlaku tanpa diperpanjang
+func (l *Locker) TTL() time.Duration {
+	return l.ttl
+}
+
+// Acquire mengambil lease key jika belum dimiliki siapa pun, atau memperpanjangnya
+// jika sudah dimiliki owner ini. Mengembalikan false jika lease dimiliki owner lain.
+func (l *Locker) Acquire(ctx context.Context, key string) (bool, error) {
+	acquired, err := l.client.SetNX(ctx, l.prefix+key, l.owner, l.ttl).Result()
+	if err != nil {
+		return false, fmt.Errorf("failed to acquire lease: %w", err)
+	}
+	if acquired {
+		return true, nil
+	}
+
+	return l.Renew(ctx, key)
+}
+
+// Renew memperpanjang lease key. Mengembalikan false jika lease sudah kedaluwarsa
+// atau diambil owner lain.
+func (l *Locker) Renew(ctx context.Context, key string) (bool, error) {
+	renewed, err := renewScript.Run(ctx, l.client, []string{l.prefix + key}, l.owner, l.ttl.Milliseconds()).Int()
+	if err != nil {
+		return false, fmt.Errorf("failed to renew lease: %w", err)
+	}
+	return renewed == 1, nil
+}
+
+// Release melepas lease key jika masih dimiliki owner ini
+func (l *Locker) Release(ctx context.Context, key string) error {
+	if err := releaseScript.Run(ctx, l.client, []string{l.prefix + key}, l.owner).Err(); err != nil {
+		return fmt.Errorf("failed to release lease: %w", err)
+	}
+	return nil
+}
+
+// Holder mengembalikan owner lease key saat ini, atau string kosong jika tidak ada
+func (l *Locker) Holder(ctx context.Context, key string) (string, error) {
+	owner, err := l.client.Get(ctx, l.prefix+key).Result()
+	if err == redis.Nil {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to get lease holder: %w", err)
+	}
+	return owner, nil
+}
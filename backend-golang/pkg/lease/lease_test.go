@@ -0,0 +1,89 @@
+package lease
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// replicas membuat dua Locker yang berbagi Redis, seperti dua replica backend
+func replicas(t *testing.T, ttl time.Duration) (a, b *Locker, mr *miniredis.Miniredis) {
+	mr = miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { client.Close() })
+
+	return NewLocker(client, "lease:", "replica-a", ttl), NewLocker(client, "lease:", "replica-b", ttl), mr
+}
+
+func TestLockerIsExclusive(t *testing.T) {
+	a, b, mr := replicas(t, 10*time.Second)
+	ctx := context.Background()
+
+	ok, err := a.Acquire(ctx, "t1")
+	require.NoError(t, err)
+	assert.True(t, ok)
+
+	ok, err = b.Acquire(ctx, "t1")
+	require.NoError(t, err)
+	assert.False(t, ok, "lease held by another replica")
+
+	holder, err := b.Holder(ctx, "t1")
+	require.NoError(t, err)
+	assert.Equal(t, "replica-a", holder)
+
+	// Acquire ulang oleh pemilik memperpanjang lease
+	mr.FastForward(5 * time.Second)
+	ok, err = a.Acquire(ctx, "t1")
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, 10*time.Second, mr.TTL("lease:t1"))
+
+	// Release oleh replica lain tidak berpengaruh
+	require.NoError(t, b.Release(ctx, "t1"))
+	holder, err = a.Holder(ctx, "t1")
+	require.NoError(t, err)
+	assert.Equal(t, "replica-a", holder)
+
+	require.NoError(t, a.Release(ctx, "t1"))
+	ok, err = b.Acquire(ctx, "t1")
+	require.NoError(t, err)
+	assert.True(t, ok)
+}
+
+func TestLockerTakeoverAfterExpiry(t *testing.T) {
+	a, b, mr := replicas(t, 0)
+	ctx := context.Background()
+	assert.Equal(t, DefaultTTL, a.TTL())
+
+	ok, err := a.Acquire(ctx, "t1")
+	require.NoError(t, err)
+	require.True(t, ok)
+
+	// Replica a berhenti memperpanjang lease, misalnya karena mati
+	mr.FastForward(DefaultTTL)
+	holder, err := b.Holder(ctx, "t1")
+	require.NoError(t, err)
+	assert.Empty(t, holder)
+
+	ok, err = b.Acquire(ctx, "t1")
+	require.NoError(t, err)
+	assert.True(t, ok)
+
+	// Replica a yang kembali tidak dapat memperpanjang lease yang sudah diambil alih
+	ok, err = a.Renew(ctx, "t1")
+	require.NoError(t, err)
+	assert.False(t, ok)
+}
+
+func TestLockerRedisUnavailable(t *testing.T) {
+	a, _, mr := replicas(t, time.Second)
+	mr.Close()
+
+	_, err := a.Acquire(context.Background(), "t1")
+	assert.Error(t, err)
+}
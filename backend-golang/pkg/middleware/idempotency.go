@@ -0,0 +1,198 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/labstack/echo/v4"
+
+	"github.com/jatis/sample-stack-golang/pkg/logger"
+)
+
+const (
+	// HeaderIdempotencyKey adalah header yang dikirim client untuk menandai request idempotent
+	HeaderIdempotencyKey = "Idempotency-Key"
+
+	// HeaderIdempotentReplayed ditambahkan pada response yang diambil dari Redis
+	HeaderIdempotentReplayed = "Idempotent-Replayed"
+
+	// DefaultIdempotencyTTL adalah lama response disimpan jika TTL tidak dikonfigurasi
+	DefaultIdempotencyTTL = 24 * time.Hour
+
+	// maxIdempotencyKeyLength adalah panjang maksimal Idempotency-Key
+	maxIdempotencyKeyLength = 255
+
+	// idempotencyLockTTL membatasi umur penanda "processing" jika proses mati sebelum response disimpan
+	idempotencyLockTTL = time.Minute
+
+	idempotencyKeyPrefix = "idempotency:"
+)
+
+// idempotencyRecord adalah data yang disimpan di Redis untuk satu Idempotency-Key
+type idempotencyRecord struct {
+	// Processing bernilai true selama request pertama belum selesai
+	Processing  bool   `json:"processing,omitempty"`
+	Fingerprint string `json:"fingerprint"`
+	MessageID   string `json:"message_id,omitempty"`
+	Status      int    `json:"status,omitempty"`
+	ContentType string `json:"content_type,omitempty"`
+	Body        []byte `json:"body,omitempty"`
+}
+
+// Idempotency mengembalikan middleware yang menyimpan response sukses (2xx) di Redis
+// berdasarkan header Idempotency-Key selama ttl. Request ulang dengan key yang sama
+// mendapat response asli tanpa menjalankan handler lagi, sehingga retry dari client
+// tidak membuat pesan duplikat. Key berlaku per method dan path, dan request ulang
+// dengan body berbeda ditolak. Request tanpa header diteruskan seperti biasa.
+func Idempotency(client *redis.Client, ttl time.Duration) echo.MiddlewareFunc {
+	if ttl <= 0 {
+		ttl = DefaultIdempotencyTTL
+	}
+
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			key := c.Request().Header.Get(HeaderIdempotencyKey)
+			if key == "" {
+				return next(c)
+			}
+			if len(key) > maxIdempotencyKeyLength {
+				return c.JSON(http.StatusBadRequest, map[string]string{"error": "Idempotency-Key must not be longer than 255 characters"})
+			}
+
+			req := c.Request()
+			ctx := req.Context()
+
+			body, err := io.ReadAll(req.Body)
+			if err != nil {
+				return c.JSON(http.StatusBadRequest, map[string]string{"error": "failed to read request body"})
+			}
+			req.Body = io.NopCloser(bytes.NewReader(body))
+
+			sum := sha256.Sum256(body)
+			fingerprint := hex.EncodeToString(sum[:])
+			redisKey := idempotencyKeyPrefix + req.Method + ":" + req.URL.Path + ":" + key
+
+			// Tandai key sedang diproses; hanya satu request yang boleh menjalankan handler
+			lock, err := json.Marshal(idempotencyRecord{Processing: true, Fingerprint: fingerprint})
+			if err != nil {
+				return err
+			}
+			acquired, err := client.SetNX(ctx, redisKey, lock, idempotencyLockTTL).Result()
+			if err != nil {
+				logger.Log.WithFields(map[string]interface{}{
+					"idempotency_key": key,
+					"error":           err,
+				}).Error("Failed to check idempotency key")
+				return c.JSON(http.StatusServiceUnavailable, map[string]string{"error": "idempotency store unavailable"})
+			}
+
+			if !acquired {
+				return replayIdempotentResponse(c, client, redisKey, fingerprint)
+			}
+
+			// Tangkap response agar dapat disimpan setelah handler selesai
+			recorder := &responseRecorder{ResponseWriter: c.Response().Writer}
+			c.Response().Writer = recorder
+
+			handlerErr := next(c)
+
+			status := c.Response().Status
+			if handlerErr != nil || status < http.StatusOK || status >= http.StatusMultipleChoices {
+				// Request gagal tidak disimpan sehingga client dapat mencoba ulang dengan key yang sama
+				if err := client.Del(context.Background(), redisKey).Err(); err != nil {
+					logger.Log.WithFields(map[string]interface{}{
+						"idempotency_key": key,
+						"error":           err,
+					}).Warn("Failed to release idempotency key")
+				}
+				return handlerErr
+			}
+
+			record := idempotencyRecord{
+				Fingerprint: fingerprint,
+				MessageID:   responseMessageID(recorder.body.Bytes()),
+				Status:      status,
+				ContentType: c.Response().Header().Get(echo.HeaderContentType),
+				Body:        recorder.body.Bytes(),
+			}
+			data, err := json.Marshal(record)
+			if err == nil {
+				// Gunakan context baru karena context request bisa sudah dibatalkan
+				err = client.Set(context.Background(), redisKey, data, ttl).Err()
+			}
+			if err != nil {
+				logger.Log.WithFields(map[string]interface{}{
+					"idempotency_key": key,
+					"error":           err,
+				}).Error("Failed to store idempotent response")
+			}
+
+			return nil
+		}
+	}
+}
+
+// replayIdempotentResponse mengirim ulang response yang tersimpan untuk redisKey
+func replayIdempotentResponse(c echo.Context, client *redis.Client, redisKey, fingerprint string) error {
+	data, err := client.Get(c.Request().Context(), redisKey).Bytes()
+	if err == redis.Nil {
+		// Key kedaluwarsa atau dilepas tepat setelah SETNX gagal
+		return c.JSON(http.StatusConflict, map[string]string{"error": "a request with this Idempotency-Key is in progress, retry later"})
+	}
+	if err != nil {
+		return c.JSON(http.StatusServiceUnavailable, map[string]string{"error": "idempotency store unavailable"})
+	}
+
+	var record idempotencyRecord
+	if err := json.Unmarshal(data, &record); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "invalid idempotency record"})
+	}
+
+	if record.Fingerprint != fingerprint {
+		return c.JSON(http.StatusUnprocessableEntity, map[string]string{"error": "Idempotency-Key was already used with a different request body"})
+	}
+	if record.Processing {
+		return c.JSON(http.StatusConflict, map[string]string{"error": "a request with this Idempotency-Key is in progress, retry later"})
+	}
+
+	logger.Log.WithFields(map[string]interface{}{
+		"idempotency_key": c.Request().Header.Get(HeaderIdempotencyKey),
+		"message_id":      record.MessageID,
+	}).Info("Replaying idempotent response")
+
+	c.Response().Header().Set(HeaderIdempotentReplayed, "true")
+	return c.Blob(record.Status, record.ContentType, record.Body)
+}
+
+// responseMessageID mengambil ID pesan dari response JSON ("message_id" atau "id")
+func responseMessageID(body []byte) string {
+	var fields struct {
+		MessageID string `json:"message_id"`
+		ID        string `json:"id"`
+	}
+	if err := json.Unmarshal(body, &fields); err != nil {
+		return ""
+	}
+	if fields.MessageID != "" {
+		return fields.MessageID
+	}
+	return fields.ID
+}
+
+// responseRecorder meneruskan response ke client sambil menyalin body-nya
+type responseRecorder struct {
+	http.ResponseWriter
+	body bytes.Buffer
+}
+
+func (r *responseRecorder) Write(b []byte) (int, error) {
+	r.body.Write(b)
+	return r.ResponseWriter.Write(b)
+}
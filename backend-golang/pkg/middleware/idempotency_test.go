@@ -0,0 +1,112 @@
+package middleware
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+	"github.com/labstack/echo/v4"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/jatis/sample-stack-golang/pkg/logger"
+)
+
+func init() {
+	logger.Log = logrus.New()
+	logger.Log.SetOutput(io.Discard)
+}
+
+// publishServer adalah endpoint publish palsu di belakang middleware Idempotency
+type publishServer struct {
+	echo   *echo.Echo
+	redis  *miniredis.Miniredis
+	calls  int
+	status int
+}
+
+func newPublishServer(t *testing.T) *publishServer {
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { client.Close() })
+
+	s := &publishServer{echo: echo.New(), redis: mr, status: http.StatusCreated}
+	s.echo.POST("/tenants/:id/publish", func(c echo.Context) error {
+		s.calls++
+		if s.status >= http.StatusBadRequest {
+			return c.JSON(s.status, map[string]string{"error": "broker unavailable"})
+		}
+		return c.JSON(s.status, map[string]string{"message_id": "m1"})
+	}, Idempotency(client, time.Hour))
+
+	return s
+}
+
+func (s *publishServer) publish(key, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/tenants/t1/publish", strings.NewReader(body))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	if key != "" {
+		req.Header.Set(HeaderIdempotencyKey, key)
+	}
+	rec := httptest.NewRecorder()
+	s.echo.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestIdempotencyReplaysStoredResponse(t *testing.T) {
+	s := newPublishServer(t)
+
+	first := s.publish("k1", `{"a":1}`)
+	assert.Equal(t, http.StatusCreated, first.Code)
+	assert.Empty(t, first.Header().Get(HeaderIdempotentReplayed))
+	assert.Equal(t, []string{"idempotency:POST:/tenants/t1/publish:k1"}, s.redis.Keys())
+
+	retry := s.publish("k1", `{"a":1}`)
+	assert.Equal(t, http.StatusCreated, retry.Code)
+	assert.Equal(t, "true", retry.Header().Get(HeaderIdempotentReplayed))
+	assert.JSONEq(t, `{"message_id":"m1"}`, retry.Body.String())
+
+	// Body berbeda dengan key yang sama ditolak
+	assert.Equal(t, http.StatusUnprocessableEntity, s.publish("k1", `{"a":2}`).Code)
+	assert.Equal(t, 1, s.calls)
+}
+
+func TestIdempotencyRejectsRequestInProgress(t *testing.T) {
+	s := newPublishServer(t)
+	s.redis.Set("idempotency:POST:/tenants/t1/publish:k1",
+		`{"processing":true,"fingerprint":"015abd7f5cc57a2dd94b7590f04ad8084273905ee33ec5cebeae62276a97f862"}`)
+
+	assert.Equal(t, http.StatusConflict, s.publish("k1", `{"a":1}`).Code)
+	assert.Equal(t, 0, s.calls)
+}
+
+func TestIdempotencyReleasesKeyOnFailure(t *testing.T) {
+	s := newPublishServer(t)
+	s.status = http.StatusServiceUnavailable
+
+	assert.Equal(t, http.StatusServiceUnavailable, s.publish("k1", `{"a":1}`).Code)
+	assert.Empty(t, s.redis.Keys())
+
+	// Client dapat mencoba ulang dengan key yang sama setelah broker pulih
+	s.status = http.StatusCreated
+	assert.Equal(t, http.StatusCreated, s.publish("k1", `{"a":1}`).Code)
+	assert.Equal(t, 2, s.calls)
+}
+
+func TestIdempotencyWithoutUsableKey(t *testing.T) {
+	s := newPublishServer(t)
+
+	s.publish("", `{"a":1}`)
+	s.publish("", `{"a":1}`)
+	assert.Equal(t, 2, s.calls)
+
+	rec := s.publish(strings.Repeat("k", maxIdempotencyKeyLength+1), `{"a":1}`)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Equal(t, 2, s.calls)
+	assert.Empty(t, s.redis.Keys())
+}
@@ -0,0 +1,92 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/jatis/sample-stack-golang/pkg/infrastructure/metrics"
+	"github.com/jatis/sample-stack-golang/pkg/logger"
+	"github.com/jatis/sample-stack-golang/pkg/ratelimit"
+)
+
+// LimitFunc mengembalikan limit publish untuk tenant
+type LimitFunc func(ctx context.Context, tenantID string) (ratelimit.Limit, error)
+
+// CostFunc mengembalikan jumlah token yang dibutuhkan request (mis. jumlah pesan dalam batch)
+type CostFunc func(c echo.Context) (int, error)
+
+// RateLimitConfig adalah konfigurasi middleware RateLimit
+type RateLimitConfig struct {
+	// Limiter menyimpan token bucket di Redis sehingga limit dibagi antar replica
+	Limiter *ratelimit.Limiter
+	// Limits mengembalikan limit tenant; error membuat request diteruskan tanpa limit
+	Limits LimitFunc
+	// Param adalah nama path parameter yang berisi tenant ID
+	Param string
+	// Cost menghitung jumlah token per request; nil berarti 1 token
+	Cost CostFunc
+}
+
+// RateLimit mengembalikan middleware yang membatasi request per tenant dengan token
+// bucket. Request yang melebihi limit mendapat 429 dengan header Retry-After dan
+// dicatat di metric tenant_publish_rate_limited_total. Jika Redis tidak tersedia,
+// request tetap diteruskan agar publish tidak berhenti total.
+func RateLimit(config RateLimitConfig) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			tenantID := c.Param(config.Param)
+			if tenantID == "" {
+				return next(c)
+			}
+
+			ctx := c.Request().Context()
+
+			limit, err := config.Limits(ctx, tenantID)
+			if err != nil || limit.Unlimited() {
+				// Tenant tidak ditemukan atau tidak dibatasi; handler yang menangani 404
+				return next(c)
+			}
+
+			cost := 1
+			if config.Cost != nil {
+				if cost, err = config.Cost(c); err != nil {
+					return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+				}
+			}
+
+			allowed, retryAfter, err := config.Limiter.Allow(ctx, tenantID, limit, cost)
+			if errors.Is(err, ratelimit.ErrCostExceedsBurst) {
+				metrics.RecordPublishRateLimited(tenantID)
+				return c.JSON(http.StatusTooManyRequests, map[string]string{
+					"error": fmt.Sprintf("request needs %d tokens but the tenant burst limit is lower, split the request", cost),
+				})
+			}
+			if err != nil {
+				logger.Log.WithFields(map[string]interface{}{
+					"tenant_id": tenantID,
+					"error":     err,
+				}).Warn("Rate limiter unavailable, allowing request")
+				return next(c)
+			}
+
+			if !allowed {
+				metrics.RecordPublishRateLimited(tenantID)
+
+				seconds := int(math.Ceil(retryAfter.Seconds()))
+				if seconds < 1 {
+					seconds = 1
+				}
+				c.Response().Header().Set("Retry-After", strconv.Itoa(seconds))
+				return c.JSON(http.StatusTooManyRequests, map[string]string{"error": "rate limit exceeded"})
+			}
+
+			return next(c)
+		}
+	}
+}
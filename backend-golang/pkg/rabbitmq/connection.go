@@ -0,0 +1,233 @@
+package rabbitmq
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/streadway/amqp"
+	"github.com/jatis/sample-stack-golang/pkg/logger"
+)
+
+const (
+	// DefaultReconnectInitialInterval adalah jeda awal sebelum mencoba dial ulang
+	DefaultReconnectInitialInterval = time.Second
+
+	// DefaultReconnectMaxInterval adalah batas atas jeda backoff dial ulang
+	DefaultReconnectMaxInterval = 30 * time.Second
+)
+
+// ErrConnectionClosed dikembalikan jika Connection sudah ditutup dengan Close
+var ErrConnectionClosed = errors.New("rabbitmq connection closed")
+
+// DialFunc membuka koneksi AMQP baru
+type DialFunc func() (*amqp.Connection, error)
+
+// Connection membungkus *amqp.Connection dan melakukan dial ulang secara otomatis
+// dengan exponential backoff ketika koneksi ditutup oleh broker atau jaringan.
+// Setelah koneksi pulih, semua hook yang didaftarkan dengan OnReconnect dipanggil
+// agar pemilik channel dapat membuka channel baru dan mendeklarasikan ulang queue.
+type Connection struct {
+	dial            DialFunc
+	initialInterval time.Duration
+	maxInterval     time.Duration
+
+	mu    sync.RWMutex
+	conn  *amqp.Connection
+	hooks []func()
+
+	closeOnce sync.Once
+	done      chan struct{}
+}
+
+// Dial membuka koneksi ke url dan mengembalikan Connection yang dapat pulih sendiri
+func Dial(url string, initialInterval, maxInterval time.Duration) (*Connection, error) {
+	dial := func() (*amqp.Connection, error) {
+		return amqp.Dial(url)
+	}
+
+	conn, err := dial()
+	if err != nil {
+		return nil, err
+	}
+
+	c := NewConnection(conn, dial)
+	c.SetBackoff(initialInterval, maxInterval)
+
+	return c, nil
+}
+
+// NewConnection membungkus koneksi yang sudah terbuka. Jika dial nil, koneksi
+// tidak akan di-dial ulang ketika terputus.
+func NewConnection(conn *amqp.Connection, dial DialFunc) *Connection {
+	c := &Connection{
+		dial:            dial,
+		initialInterval: DefaultReconnectInitialInterval,
+		maxInterval:     DefaultReconnectMaxInterval,
+		conn:            conn,
+		done:            make(chan struct{}),
+	}
+
+	if dial != nil {
+		// NotifyClose didaftarkan sebelum watcher berjalan agar penutupan yang terjadi
+		// segera setelah koneksi dibuka tidak terlewat
+		go c.watch(conn.NotifyClose(make(chan *amqp.Error, 1)))
+	}
+
+	return c
+}
+
+// SetBackoff mengubah interval backoff dial ulang. Nilai <= 0 memakai default.
+func (c *Connection) SetBackoff(initialInterval, maxInterval time.Duration) {
+	if initialInterval <= 0 {
+		initialInterval = DefaultReconnectInitialInterval
+	}
+	if maxInterval < initialInterval {
+		maxInterval = DefaultReconnectMaxInterval
+		if maxInterval < initialInterval {
+			maxInterval = initialInterval
+		}
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.initialInterval = initialInterval
+	c.maxInterval = maxInterval
+}
+
+// OnReconnect mendaftarkan hook yang dipanggil setiap kali koneksi berhasil dibuka ulang
+func (c *Connection) OnReconnect(hook func()) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.hooks = append(c.hooks, hook)
+}
+
+// Channel membuka channel baru pada koneksi saat ini
+func (c *Connection) Channel() (*amqp.Channel, error) {
+	select {
+	case <-c.done:
+		return nil, ErrConnectionClosed
+	default:
+	}
+
+	c.mu.RLock()
+	conn := c.conn
+	c.mu.RUnlock()
+
+	return conn.Channel()
+}
+
+// IsClosed mengembalikan true jika koneksi saat ini sedang terputus atau sudah ditutup
+func (c *Connection) IsClosed() bool {
+	select {
+	case <-c.done:
+		return true
+	default:
+	}
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.conn == nil || c.conn.IsClosed()
+}
+
+// Close menutup koneksi dan menghentikan proses dial ulang
+func (c *Connection) Close() error {
+	var err error
+	c.closeOnce.Do(func() {
+		close(c.done)
+
+		c.mu.RLock()
+		conn := c.conn
+		c.mu.RUnlock()
+
+		if conn != nil && !conn.IsClosed() {
+			err = conn.Close()
+		}
+	})
+
+	return err
+}
+
+// watch menunggu NotifyClose dari koneksi lalu melakukan dial ulang
+func (c *Connection) watch(closed chan *amqp.Error) {
+	for {
+		select {
+		case <-c.done:
+			return
+		case amqpErr := <-closed:
+			select {
+			case <-c.done:
+				// Ditutup secara normal (Close dipanggil), tidak perlu dial ulang
+				return
+			default:
+			}
+
+			// Channel NotifyClose juga ditutup tanpa error ketika koneksi sudah mati
+			// sebelum didaftarkan; tetap dial ulang karena Close tidak dipanggil
+			fields := map[string]interface{}{}
+			if amqpErr != nil {
+				fields["code"] = amqpErr.Code
+				fields["reason"] = amqpErr.Reason
+			}
+			logger.Log.WithFields(fields).Error("RabbitMQ connection lost, reconnecting")
+		}
+
+		conn := c.redial()
+		if conn == nil {
+			return
+		}
+		closed = conn.NotifyClose(make(chan *amqp.Error, 1))
+
+		c.mu.Lock()
+		c.conn = conn
+		hooks := append([]func(){}, c.hooks...)
+		c.mu.Unlock()
+
+		logger.Log.Info("RabbitMQ connection re-established")
+
+		for _, hook := range hooks {
+			hook()
+		}
+	}
+}
+
+// redial mencoba dial ulang dengan exponential backoff sampai berhasil atau Close dipanggil
+func (c *Connection) redial() *amqp.Connection {
+	c.mu.RLock()
+	interval, maxInterval := c.initialInterval, c.maxInterval
+	c.mu.RUnlock()
+
+	for attempt := 1; ; attempt++ {
+		select {
+		case <-c.done:
+			return nil
+		case <-time.After(interval):
+		}
+
+		conn, err := c.dial()
+		if err == nil {
+			select {
+			case <-c.done:
+				// Close dipanggil selama dial berlangsung
+				conn.Close()
+				return nil
+			default:
+			}
+			return conn
+		}
+
+		interval *= 2
+		if interval > maxInterval {
+			interval = maxInterval
+		}
+
+		logger.Log.WithFields(map[string]interface{}{
+			"attempt":    attempt,
+			"next_retry": interval.String(),
+			"error":      err,
+		}).Warn("Failed to reconnect to RabbitMQ")
+	}
+}
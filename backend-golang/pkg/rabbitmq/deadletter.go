@@ -1,6 +1,7 @@
 package rabbitmq
 
 import (
+	"context"
 	"fmt"
 	"time"
 
@@ -14,6 +15,15 @@ const (
 
 	// DefaultMessageTTL adalah waktu hidup default untuk pesan dalam milidetik (24 jam)
 	DefaultMessageTTL = int32(1000 * 60 * 60 * 24)
+
+	// DefaultRetryBaseDelay adalah delay dasar untuk exponential backoff (retry ke-n = base * 2^n)
+	DefaultRetryBaseDelay = time.Second
+
+	// RetryCountHeader adalah header yang menyimpan jumlah retry yang sudah dilakukan
+	RetryCountHeader = "x-retry-count"
+
+	// LastErrorHeader adalah header yang menyimpan error pemrosesan terakhir
+	LastErrorHeader = "x-last-error"
 )
 
 // DeadLetterConfig berisi konfigurasi untuk dead letter queue
@@ -24,6 +34,12 @@ type DeadLetterConfig struct {
 	// QueuePrefix adalah prefix untuk nama dead letter queue
 	QueuePrefix string
 
+	// RetryQueuePrefix adalah prefix untuk nama retry queue (retry.tenant.<id>.<n>)
+	RetryQueuePrefix string
+
+	// RetryBaseDelay adalah delay dasar untuk exponential backoff retry
+	RetryBaseDelay time.Duration
+
 	// MessageTTL adalah waktu hidup pesan dalam milidetik
 	MessageTTL int32
 
@@ -34,10 +50,12 @@ type DeadLetterConfig struct {
 // NewDefaultDeadLetterConfig membuat DeadLetterConfig dengan nilai default
 func NewDefaultDeadLetterConfig() *DeadLetterConfig {
 	return &DeadLetterConfig{
-		ExchangeName: "dlx.tenant",
-		QueuePrefix:  "dlq.tenant",
-		MessageTTL:   DefaultMessageTTL,
-		MaxRetries:   DefaultMaxRetries,
+		ExchangeName:     "dlx.tenant",
+		QueuePrefix:      "dlq.tenant",
+		RetryQueuePrefix: "retry.tenant",
+		RetryBaseDelay:   DefaultRetryBaseDelay,
+		MessageTTL:       DefaultMessageTTL,
+		MaxRetries:       DefaultMaxRetries,
 	}
 }
 
@@ -63,7 +81,7 @@ func SetupDeadLetterExchange(ch *amqp.Channel, config *DeadLetterConfig) error {
 // SetupDeadLetterQueue membuat dan mengkonfigurasi dead letter queue untuk tenant tertentu
 func SetupDeadLetterQueue(ch *amqp.Channel, tenantID string, config *DeadLetterConfig) (string, error) {
 	// Declare dead-letter queue
-	dlqName := fmt.Sprintf("%s.%s", config.QueuePrefix, tenantID)
+	dlqName := config.DeadLetterQueueName(tenantID)
 	_, err := ch.QueueDeclare(
 		dlqName,
 		true,  // durable
@@ -94,6 +112,69 @@ func SetupDeadLetterQueue(ch *amqp.Channel, tenantID string, config *DeadLetterC
 	return routingKey, nil
 }
 
+// DeadLetterQueueName mengembalikan nama dead letter queue untuk tenant
+func (c *DeadLetterConfig) DeadLetterQueueName(tenantID string) string {
+	return fmt.Sprintf("%s.%s", c.QueuePrefix, tenantID)
+}
+
+// RetryQueueName mengembalikan nama retry queue untuk percobaan ke-n
+func (c *DeadLetterConfig) RetryQueueName(tenantID string, attempt int32) string {
+	return fmt.Sprintf("%s.%s.%d", c.RetryQueuePrefix, tenantID, attempt)
+}
+
+// RetryDelay mengembalikan delay untuk percobaan ke-n (2, 4, 8 detik dengan base 1 detik)
+func (c *DeadLetterConfig) RetryDelay(attempt int32) time.Duration {
+	return c.RetryBaseDelay * time.Duration(1<<attempt)
+}
+
+// SetupRetryQueues membuat retry queue untuk setiap percobaan retry tenant.
+// Setiap retry queue memiliki TTL sesuai backoff dan melakukan dead-letter kembali
+// ke main queue tenant melalui default exchange setelah TTL habis.
+func SetupRetryQueues(ch *amqp.Channel, tenantID string, config *DeadLetterConfig) error {
+	mainQueue := fmt.Sprintf("tenant.%s", tenantID)
+
+	for attempt := int32(1); attempt <= config.MaxRetries; attempt++ {
+		_, err := ch.QueueDeclare(
+			config.RetryQueueName(tenantID, attempt),
+			true,  // durable
+			false, // delete when unused
+			false, // exclusive
+			false, // no-wait
+			amqp.Table{
+				"x-message-ttl":             int32(config.RetryDelay(attempt).Milliseconds()),
+				"x-dead-letter-exchange":    "", // default exchange
+				"x-dead-letter-routing-key": mainQueue,
+			},
+		)
+		if err != nil {
+			return fmt.Errorf("failed to declare retry queue %d: %w", attempt, err)
+		}
+	}
+
+	return nil
+}
+
+// DeclareTenantQueue membuat main queue tenant (tenant.<id>) dengan dead-letter ke DLQ
+// tenant dan mengembalikan nama queue-nya. Dead letter exchange harus sudah dibuat.
+func DeclareTenantQueue(ch *amqp.Channel, tenantID string, config *DeadLetterConfig) (string, error) {
+	queueName := fmt.Sprintf("tenant.%s", tenantID)
+	args := GetDeadLetterArgs(config.ExchangeName, queueName, config.MessageTTL)
+
+	_, err := ch.QueueDeclare(
+		queueName,
+		true,  // durable
+		false, // delete when unused
+		false, // exclusive
+		false, // no-wait
+		args,  // arguments with dead-letter configuration
+	)
+	if err != nil {
+		return "", fmt.Errorf("failed to declare queue: %v", err)
+	}
+
+	return queueName, nil
+}
+
 // GetDeadLetterArgs mengembalikan arguments untuk queue dengan dead letter configuration
 func GetDeadLetterArgs(dlxName, routingKey string, ttl int32) amqp.Table {
 	return amqp.Table{
@@ -103,159 +184,390 @@ func GetDeadLetterArgs(dlxName, routingKey string, ttl int32) amqp.Table {
 	}
 }
 
-// HandleMessageProcessingError menangani error pemrosesan pesan dengan retry logic
+// GetRetryCount membaca header x-retry-count dari pesan
+func GetRetryCount(headers amqp.Table) int32 {
+	if headers == nil {
+		return 0
+	}
+
+	switch v := headers[RetryCountHeader].(type) {
+	case int32:
+		return v
+	case int64:
+		return int32(v)
+	case int:
+		return int32(v)
+	case int16:
+		return int32(v)
+	case int8:
+		return int32(v)
+	case float64:
+		return int32(v)
+	case float32:
+		return int32(v)
+	default:
+		return 0
+	}
+}
+
+// GetLastError membaca header x-last-error dari pesan
+func GetLastError(headers amqp.Table) string {
+	if headers == nil {
+		return ""
+	}
+
+	lastError, _ := headers[LastErrorHeader].(string)
+	return lastError
+}
+
+// PeekMessages mengambil hingga limit pesan dari queue tanpa menghapusnya.
+// Pesan diambil dengan basic.get tanpa ack lalu di-nack dengan requeue=true
+// sekaligus, sehingga pesan kembali ke queue. Channel sebaiknya khusus dipakai
+// untuk operasi ini karena pesan tetap unacked sampai fungsi selesai.
+func PeekMessages(ch *amqp.Channel, queueName string, limit int) ([]amqp.Delivery, error) {
+	messages := make([]amqp.Delivery, 0, limit)
+	for len(messages) < limit {
+		msg, ok, err := ch.Get(queueName, false)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get message from %s: %w", queueName, err)
+		}
+		if !ok {
+			break
+		}
+		messages = append(messages, msg)
+	}
+
+	if len(messages) > 0 {
+		// Nack multiple dengan requeue mengembalikan semua pesan yang diambil ke queue
+		lastTag := messages[len(messages)-1].DeliveryTag
+		if err := ch.Nack(lastTag, true, true); err != nil {
+			return nil, fmt.Errorf("failed to requeue peeked messages: %w", err)
+		}
+	}
+
+	return messages, nil
+}
+
+// ReplayDeadLetters memindahkan pesan dari dlq.tenant.<id> kembali ke tenant.<id>
+// dengan header retry di-reset. Limit <= 0 berarti semua pesan yang ada di DLQ saat
+// replay dimulai. Channel akan di-set ke confirm mode dan pesan di DLQ hanya di-ack
+// setelah broker mengonfirmasi publish ke main queue.
+func ReplayDeadLetters(ch *amqp.Channel, tenantID string, config *DeadLetterConfig, limit int) (int, error) {
+	dlqName := config.DeadLetterQueueName(tenantID)
+	queue, err := ch.QueueInspect(dlqName)
+	if err != nil {
+		return 0, fmt.Errorf("failed to inspect dead-letter queue: %w", err)
+	}
+
+	// Batasi jumlah pesan berdasarkan snapshot agar replay tidak berputar tanpa henti
+	if limit <= 0 || limit > queue.Messages {
+		limit = queue.Messages
+	}
+
+	confirms, returns, err := enableConfirms(ch)
+	if err != nil {
+		return 0, err
+	}
+
+	replayed := 0
+	for replayed < limit {
+		msg, ok, err := ch.Get(dlqName, false)
+		if err != nil {
+			return replayed, fmt.Errorf("failed to get message from dead-letter queue: %w", err)
+		}
+		if !ok {
+			break
+		}
+
+		if err := replayDelivery(ch, confirms, returns, tenantID, msg); err != nil {
+			return replayed, err
+		}
+		replayed++
+	}
+
+	return replayed, nil
+}
+
+// ReplayDeadLetter memindahkan satu pesan dengan MessageId tertentu dari DLQ ke main queue.
+// Pesan lain yang terbaca selama pencarian dikembalikan ke DLQ.
+func ReplayDeadLetter(ch *amqp.Channel, tenantID string, config *DeadLetterConfig, messageID string) (bool, error) {
+	dlqName := config.DeadLetterQueueName(tenantID)
+	queue, err := ch.QueueInspect(dlqName)
+	if err != nil {
+		return false, fmt.Errorf("failed to inspect dead-letter queue: %w", err)
+	}
+
+	confirms, returns, err := enableConfirms(ch)
+	if err != nil {
+		return false, err
+	}
+
+	var skipped []amqp.Delivery
+	defer func() {
+		// Kembalikan pesan yang tidak cocok ke DLQ
+		for _, d := range skipped {
+			if err := d.Nack(false, true); err != nil {
+				logger.Log.WithFields(map[string]interface{}{
+					"tenant_id":  tenantID,
+					"message_id": d.MessageId,
+					"error":      err,
+				}).Error("[DLQ] Failed to requeue skipped message")
+			}
+		}
+	}()
+
+	for i := 0; i < queue.Messages; i++ {
+		msg, ok, err := ch.Get(dlqName, false)
+		if err != nil {
+			return false, fmt.Errorf("failed to get message from dead-letter queue: %w", err)
+		}
+		if !ok {
+			break
+		}
+
+		if msg.MessageId != messageID {
+			skipped = append(skipped, msg)
+			continue
+		}
+
+		if err := replayDelivery(ch, confirms, returns, tenantID, msg); err != nil {
+			return false, err
+		}
+		return true, nil
+	}
+
+	return false, nil
+}
+
+// PurgeDeadLetters menghapus semua pesan di DLQ tenant dan mengembalikan jumlah pesan yang dihapus
+func PurgeDeadLetters(ch *amqp.Channel, tenantID string, config *DeadLetterConfig) (int, error) {
+	purged, err := ch.QueuePurge(config.DeadLetterQueueName(tenantID), false)
+	if err != nil {
+		return 0, fmt.Errorf("failed to purge dead-letter queue: %w", err)
+	}
+	return purged, nil
+}
+
+// enableConfirms mengaktifkan publisher confirm pada channel dan mendaftarkan channel
+// untuk pesan mandatory yang dikembalikan broker
+func enableConfirms(ch *amqp.Channel) (chan amqp.Confirmation, chan amqp.Return, error) {
+	if err := ch.Confirm(false); err != nil {
+		return nil, nil, fmt.Errorf("failed to enable publisher confirms: %w", err)
+	}
+	returns := ch.NotifyReturn(make(chan amqp.Return, 1))
+	return ch.NotifyPublish(make(chan amqp.Confirmation, 1)), returns, nil
+}
+
+// replayDelivery mempublikasikan ulang pesan DLQ ke main queue tenant dengan header retry
+// di-reset, lalu melakukan ack setelah publish dikonfirmasi broker. Publish bersifat
+// mandatory: jika main queue tidak ada, broker mengembalikan pesan sehingga pesan DLQ
+// di-requeue dan ErrUnroutable dikembalikan. Jika confirm tidak diterima dalam
+// DefaultConfirmTimeout, pesan DLQ juga di-requeue dan ErrConfirmTimeout dikembalikan.
+func replayDelivery(ch *amqp.Channel, confirms chan amqp.Confirmation, returns chan amqp.Return, tenantID string, msg amqp.Delivery) error {
+	headers := amqp.Table{}
+	for k, v := range msg.Headers {
+		headers[k] = v
+	}
+	delete(headers, RetryCountHeader)
+	delete(headers, LastErrorHeader)
+
+	mainQueue := fmt.Sprintf("tenant.%s", tenantID)
+	if err := ch.Publish("", mainQueue, true, false, republishing(msg, headers)); err != nil {
+		msg.Nack(false, true)
+		return fmt.Errorf("failed to republish message to %s: %w", mainQueue, err)
+	}
+
+	timer := time.NewTimer(DefaultConfirmTimeout)
+	defer timer.Stop()
+
+	select {
+	case confirm := <-confirms:
+		if !confirm.Ack {
+			msg.Nack(false, true)
+			return fmt.Errorf("broker did not confirm replay of message %s", msg.MessageId)
+		}
+	case <-timer.C:
+		// Pesan DLQ dikembalikan agar tidak tertahan unacked selama channel masih terbuka
+		msg.Nack(false, true)
+		return fmt.Errorf("%w: replay of message %s", ErrConfirmTimeout, msg.MessageId)
+	}
+
+	// Broker mengirim basic.return sebelum basic.ack, sehingga return sudah tersedia di sini
+	select {
+	case ret := <-returns:
+		msg.Nack(false, true)
+		return fmt.Errorf("%w: %s (%d %s)", ErrUnroutable, ret.RoutingKey, ret.ReplyCode, ret.ReplyText)
+	default:
+	}
+
+	if err := msg.Ack(false); err != nil {
+		return fmt.Errorf("failed to ack replayed message: %w", err)
+	}
+
+	logger.Log.WithFields(map[string]interface{}{
+		"tenant_id":  tenantID,
+		"message_id": msg.MessageId,
+	}).Info("[DLQ] Pesan berhasil di-replay ke main queue")
+
+	return nil
+}
+
+// HandleMessageProcessingError menangani error pemrosesan pesan dengan retry logic.
+//
+// Selama retry belum habis, pesan dipublikasikan ulang ke retry queue
+// (retry.tenant.<id>.<n>) dengan header x-retry-count yang di-increment. Setelah TTL
+// retry queue habis, RabbitMQ mengembalikan pesan ke main queue tenant. Pesan yang
+// sudah mencapai batas retry dipublikasikan ke dead letter exchange sehingga masuk
+// ke dlq.tenant.<id>. Publish dilakukan melalui Publisher (channel confirm-mode dengan
+// mandatory), dan pesan asli di-ack hanya setelah broker mengonfirmasi publish. Jika
+// publish gagal, dikembalikan broker, atau tidak dikonfirmasi, pesan yang retry-nya
+// belum habis di-nack dengan requeue sehingga dicoba lagi, sedangkan pesan yang sudah
+// mencapai batas retry di-reject sehingga masuk ke DLQ melalui konfigurasi DLX main queue.
+//
+// Nilai kembalian deadLettered bernilai true jika pesan dikirim ke DLQ.
 func HandleMessageProcessingError(
+	publisher *Publisher,
 	msg amqp.Delivery,
 	processingError error,
-	queueName string,
 	tenantID string,
 	workerID int,
-	maxRetries int32,
-) error {
-	// Log awal proses penanganan error
-	logger.Log.WithFields(map[string]interface{}{
-		"tenant_id":  tenantID,
-		"worker_id":  workerID,
-		"message_id": msg.MessageId,
-		"error":      processingError,
-	}).Info("[DLQ] Mulai menangani error pemrosesan pesan")
-	// Cek apakah pesan sudah memiliki header x-retry-count
-	var retryCount int32 = 0
-	if msg.Headers != nil {
-		logger.Log.WithFields(map[string]interface{}{
-			"tenant_id":  tenantID,
-			"worker_id":  workerID,
-			"message_id": msg.MessageId,
-			"headers":    msg.Headers,
-		}).Debug("[DLQ] Memeriksa header pesan")
-
-		if retryCountVal, ok := msg.Headers["x-retry-count"]; ok {
-			logger.Log.WithFields(map[string]interface{}{
-				"tenant_id":     tenantID,
-				"worker_id":     workerID,
-				"message_id":    msg.MessageId,
-				"retry_count_raw": retryCountVal,
-				"type":          fmt.Sprintf("%T", retryCountVal),
-			}).Debug("[DLQ] Menemukan header x-retry-count")
-
-			if retryCount, ok = retryCountVal.(int32); !ok {
-				// Jika tipe tidak sesuai, coba konversi
-				if retryCountFloat, ok := retryCountVal.(float64); ok {
-					retryCount = int32(retryCountFloat)
-					logger.Log.WithFields(map[string]interface{}{
-						"tenant_id":     tenantID,
-						"worker_id":     workerID,
-						"message_id":    msg.MessageId,
-						"retry_count_float": retryCountFloat,
-						"retry_count_int":   retryCount,
-					}).Debug("[DLQ] Mengkonversi retry count dari float64 ke int32")
-				}
-			}
-		}
-	}
-
-	// Increment retry count
-	retryCount++
+	config *DeadLetterConfig,
+) (deadLettered bool, err error) {
+	retryCount := GetRetryCount(msg.Headers) + 1
 
 	logger.Log.WithFields(map[string]interface{}{
 		"tenant_id":   tenantID,
 		"worker_id":   workerID,
 		"message_id":  msg.MessageId,
+		"error":       processingError,
 		"retry_count": retryCount,
-		"max_retries": maxRetries,
-	}).Info("[DLQ] Memeriksa batas retry")
+		"max_retries": config.MaxRetries,
+	}).Info("[DLQ] Mulai menangani error pemrosesan pesan")
 
-	// Cek apakah sudah mencapai batas retry
-	if retryCount <= maxRetries {
-		// Reject pesan saat ini
-		if rejectErr := msg.Reject(false); rejectErr != nil {
-			logger.Log.WithFields(map[string]interface{}{
-				"tenant_id":   tenantID,
-				"worker_id":   workerID,
-				"message_id":  msg.MessageId,
-				"error":       rejectErr,
-				"retry_count": retryCount,
-			}).Error("Failed to reject message for retry")
-			return rejectErr
-		}
+	headers := amqp.Table{}
+	for k, v := range msg.Headers {
+		headers[k] = v
+	}
+	headers[RetryCountHeader] = retryCount
+	headers[LastErrorHeader] = processingError.Error()
 
-		// Kita perlu memodifikasi pendekatan retry karena amqp.Delivery tidak memiliki field Channel
-		// Sebagai solusi, kita akan menggunakan pendekatan lain untuk retry
-		
-		// Log retry attempt
-		delay := time.Duration(1<<retryCount) * time.Second // 2, 4, 8 seconds
-		logger.Log.WithFields(map[string]interface{}{
-			"tenant_id":   tenantID,
-			"worker_id":   workerID,
-			"message_id":  msg.MessageId,
-			"retry_count": retryCount,
-			"delay":       delay,
-		}).Info("Message will be retried via NACK with requeue=true")
-		
-		// Untuk retry, kita akan menggunakan NACK dengan requeue=true
-		// Ini akan menyebabkan pesan dikembalikan ke queue dan diproses ulang
-		// Namun, ini tidak memberikan delay. Untuk implementasi yang lebih baik,
-		// kita perlu menerima channel sebagai parameter
+	exchange := ""
+	routingKey := config.RetryQueueName(tenantID, retryCount)
+	if retryCount > config.MaxRetries {
+		// Sudah mencapai batas retry, kirim ke dead-letter queue
+		deadLettered = true
+		exchange = config.ExchangeName
+		routingKey = fmt.Sprintf("tenant.%s", tenantID)
+	}
+
+	if publisher == nil {
+		err = fmt.Errorf("publisher is nil")
+	} else {
+		err = publisher.Publish(context.Background(), exchange, routingKey, republishing(msg, headers))
+	}
+
+	if err != nil {
 		logger.Log.WithFields(map[string]interface{}{
 			"tenant_id":   tenantID,
 			"worker_id":   workerID,
 			"message_id":  msg.MessageId,
-			"retry_count": retryCount,
-		}).Info("[DLQ] Melakukan NACK dengan requeue=true untuk retry")
-
-		if nackErr := msg.Nack(false, true); nackErr != nil { // multiple=false, requeue=true
-			logger.Log.WithFields(map[string]interface{}{
-				"tenant_id":   tenantID,
-				"worker_id":   workerID,
-				"message_id":  msg.MessageId,
-				"error":       nackErr,
-				"retry_count": retryCount,
-			}).Error("[DLQ] Failed to nack message for retry")
-			return nackErr
+			"routing_key": routingKey,
+			"error":       err,
+			"dead_letter": deadLettered,
+		}).Error("[DLQ] Failed to republish message")
+
+		if !deadLettered {
+			// Retry belum habis; pesan dikembalikan ke main queue agar tidak masuk DLQ
+			// hanya karena publisher sedang bermasalah
+			if nackErr := msg.Nack(false, true); nackErr != nil {
+				return false, nackErr
+			}
+			return false, nil
+		}
+
+		// Reject tanpa requeue akan mengirim pesan ke DLQ melalui konfigurasi DLX main queue
+		if rejectErr := msg.Reject(false); rejectErr != nil {
+			return false, rejectErr
 		}
+		return true, nil
+	}
 
+	if ackErr := msg.Ack(false); ackErr != nil {
+		logger.Log.WithFields(map[string]interface{}{
+			"tenant_id":  tenantID,
+			"worker_id":  workerID,
+			"message_id": msg.MessageId,
+			"error":      ackErr,
+		}).Error("[DLQ] Failed to ack original message after republish")
+		return deadLettered, ackErr
+	}
+
+	if deadLettered {
 		logger.Log.WithFields(map[string]interface{}{
 			"tenant_id":   tenantID,
 			"worker_id":   workerID,
 			"message_id":  msg.MessageId,
 			"retry_count": retryCount,
-		}).Info("[DLQ] Pesan berhasil di-NACK untuk retry")
+		}).Error("[DLQ] Message processing failed after max retries, sent to dead-letter queue")
 	} else {
-		// Sudah mencapai batas retry, kirim ke dead-letter queue
 		logger.Log.WithFields(map[string]interface{}{
 			"tenant_id":   tenantID,
 			"worker_id":   workerID,
 			"message_id":  msg.MessageId,
-			"error":       processingError,
 			"retry_count": retryCount,
-			"max_retries": maxRetries,
-		}).Error("[DLQ] Message processing failed after max retries, sending to dead-letter queue")
+			"retry_queue": routingKey,
+			"delay":       config.RetryDelay(retryCount),
+		}).Info("[DLQ] Pesan dijadwalkan untuk retry melalui retry queue")
+	}
 
-		// Reject tanpa requeue akan mengirim ke dead-letter queue
-		logger.Log.WithFields(map[string]interface{}{
-			"tenant_id":   tenantID,
-			"worker_id":   workerID,
-			"message_id":  msg.MessageId,
-			"retry_count": retryCount,
-		}).Info("[DLQ] Melakukan REJECT tanpa requeue untuk mengirim ke DLQ")
-
-		if err := msg.Reject(false); err != nil {
-			logger.Log.WithFields(map[string]interface{}{
-				"tenant_id":  tenantID,
-				"worker_id":  workerID,
-				"message_id": msg.MessageId,
-				"error":      err,
-			}).Error("[DLQ] Failed to reject message to dead-letter queue")
-			return err
-		}
+	return deadLettered, nil
+}
 
-		logger.Log.WithFields(map[string]interface{}{
-			"tenant_id":   tenantID,
-			"worker_id":   workerID,
-			"message_id":  msg.MessageId,
-			"retry_count": retryCount,
-		}).Info("[DLQ] Pesan berhasil dikirim ke dead-letter queue")
+// DeferMessage menunda pesan dengan mempublikasikannya ulang ke retry queue pertama
+// (retry.tenant.<id>.1) tanpa menambah x-retry-count, sehingga pesan kembali ke main
+// queue setelah TTL retry queue habis. Dipakai untuk pesan yang belum boleh diproses,
+// misalnya karena MessageId yang sama sedang diproses worker lain. Pesan asli di-ack
+// setelah broker mengonfirmasi publish; jika publish gagal, pesan di-nack dengan requeue.
+func DeferMessage(publisher *Publisher, msg amqp.Delivery, tenantID string, config *DeadLetterConfig) error {
+	headers := amqp.Table{}
+	for k, v := range msg.Headers {
+		headers[k] = v
 	}
+	routingKey := config.RetryQueueName(tenantID, 1)
 
+	var err error
+	if publisher == nil {
+		err = fmt.Errorf("publisher is nil")
+	} else {
+		err = publisher.Publish(context.Background(), "", routingKey, republishing(msg, headers))
+	}
+	if err != nil {
+		if nackErr := msg.Nack(false, true); nackErr != nil {
+			return nackErr
+		}
+		return fmt.Errorf("failed to defer message to %s: %w", routingKey, err)
+	}
+
+	if err := msg.Ack(false); err != nil {
+		return fmt.Errorf("failed to ack deferred message: %w", err)
+	}
 	return nil
 }
+
+// republishing menyalin properti pesan asli untuk dipublikasikan ulang dengan header baru
+func republishing(msg amqp.Delivery, headers amqp.Table) amqp.Publishing {
+	return amqp.Publishing{
+		Headers:         headers,
+		ContentType:     msg.ContentType,
+		ContentEncoding: msg.ContentEncoding,
+		DeliveryMode:    amqp.Persistent,
+		Priority:        msg.Priority,
+		CorrelationId:   msg.CorrelationId,
+		ReplyTo:         msg.ReplyTo,
+		MessageId:       msg.MessageId,
+		Timestamp:       msg.Timestamp,
+		Type:            msg.Type,
+		AppId:           msg.AppId,
+		Body:            msg.Body,
+	}
+}
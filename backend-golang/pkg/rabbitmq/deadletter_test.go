@@ -0,0 +1,149 @@
+package rabbitmq
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/streadway/amqp"
+
+	"github.com/jatis/sample-stack-golang/pkg/logger"
+)
+
+func init() {
+	logger.Log = logrus.New()
+	logger.Log.SetOutput(io.Discard)
+}
+
+// recordingAcknowledger mencatat ack, nack, dan reject yang dilakukan pada sebuah delivery
+type recordingAcknowledger struct {
+	acked, nacked, rejected bool
+	requeue                 bool
+}
+
+func (a *recordingAcknowledger) Ack(tag uint64, multiple bool) error {
+	a.acked = true
+	return nil
+}
+
+func (a *recordingAcknowledger) Nack(tag uint64, multiple, requeue bool) error {
+	a.nacked = true
+	a.requeue = requeue
+	return nil
+}
+
+func (a *recordingAcknowledger) Reject(tag uint64, requeue bool) error {
+	a.rejected = true
+	a.requeue = requeue
+	return nil
+}
+
+func TestGetRetryCount(t *testing.T) {
+	cases := map[string]struct {
+		headers amqp.Table
+		want    int32
+	}{
+		"nil headers":      {nil, 0},
+		"missing header":   {amqp.Table{"other": int32(2)}, 0},
+		"int32":            {amqp.Table{RetryCountHeader: int32(2)}, 2},
+		"int64":            {amqp.Table{RetryCountHeader: int64(3)}, 3},
+		"int":              {amqp.Table{RetryCountHeader: 1}, 1},
+		"int16":            {amqp.Table{RetryCountHeader: int16(2)}, 2},
+		"int8":             {amqp.Table{RetryCountHeader: int8(1)}, 1},
+		"float64":          {amqp.Table{RetryCountHeader: float64(2)}, 2},
+		"float32":          {amqp.Table{RetryCountHeader: float32(3)}, 3},
+		"unsupported type": {amqp.Table{RetryCountHeader: "2"}, 0},
+	}
+
+	for name, c := range cases {
+		if got := GetRetryCount(c.headers); got != c.want {
+			t.Errorf("%s: GetRetryCount() = %d, want %d", name, got, c.want)
+		}
+	}
+}
+
+func TestRetryQueueNamesAndDelays(t *testing.T) {
+	config := NewDefaultDeadLetterConfig()
+
+	if config.MaxRetries != 3 {
+		t.Fatalf("MaxRetries = %d, want 3", config.MaxRetries)
+	}
+	if got := config.DeadLetterQueueName("abc"); got != "dlq.tenant.abc" {
+		t.Errorf("DeadLetterQueueName() = %q, want dlq.tenant.abc", got)
+	}
+
+	wantDelay := 2 * time.Second
+	for attempt := int32(1); attempt <= config.MaxRetries; attempt++ {
+		wantQueue := fmt.Sprintf("retry.tenant.abc.%d", attempt)
+		if got := config.RetryQueueName("abc", attempt); got != wantQueue {
+			t.Errorf("RetryQueueName(%d) = %q, want %q", attempt, got, wantQueue)
+		}
+		if got := config.RetryDelay(attempt); got != wantDelay {
+			t.Errorf("RetryDelay(%d) = %s, want %s", attempt, got, wantDelay)
+		}
+		wantDelay *= 2
+	}
+}
+
+func TestHandleMessageProcessingErrorWithoutPublisher(t *testing.T) {
+	config := NewDefaultDeadLetterConfig()
+
+	// Retry yang belum habis dikembalikan ke main queue, bukan ke DLQ
+	ack := &recordingAcknowledger{}
+	msg := amqp.Delivery{Acknowledger: ack, Headers: amqp.Table{RetryCountHeader: int32(1)}}
+	deadLettered, err := HandleMessageProcessingError(nil, msg, errors.New("boom"), "abc", 1, config)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if deadLettered || !ack.nacked || !ack.requeue || ack.rejected || ack.acked {
+		t.Errorf("retry left: deadLettered=%v ack=%+v, want nack with requeue", deadLettered, ack)
+	}
+
+	// Retry yang sudah habis di-reject ke DLQ
+	ack = &recordingAcknowledger{}
+	msg = amqp.Delivery{Acknowledger: ack, Headers: amqp.Table{RetryCountHeader: config.MaxRetries}}
+	deadLettered, err = HandleMessageProcessingError(nil, msg, errors.New("boom"), "abc", 1, config)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !deadLettered || !ack.rejected || ack.requeue || ack.nacked || ack.acked {
+		t.Errorf("retries exhausted: deadLettered=%v ack=%+v, want reject without requeue", deadLettered, ack)
+	}
+}
+
+func TestDeferMessage(t *testing.T) {
+	config := NewDefaultDeadLetterConfig()
+
+	// Pesan ditunda ke retry queue pertama tanpa menambah retry count
+	f := newFakeChannel(0, nil)
+	publisher := NewPublisher(nil, 1, time.Second)
+	publisher.pool <- f.confirmChannel()
+
+	ack := &recordingAcknowledger{}
+	msg := amqp.Delivery{Acknowledger: ack, MessageId: "m1", Headers: amqp.Table{RetryCountHeader: int32(2)}}
+	if err := DeferMessage(publisher, msg, "abc", config); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(f.routingKeys) != 1 || f.routingKeys[0] != "retry.tenant.abc.1" {
+		t.Fatalf("routing keys = %v, want [retry.tenant.abc.1]", f.routingKeys)
+	}
+	if got := GetRetryCount(f.published[0].Headers); got != 2 {
+		t.Errorf("deferred retry count = %d, want 2", got)
+	}
+	if !ack.acked || ack.nacked || ack.rejected {
+		t.Errorf("deferred: ack=%+v, want ack", ack)
+	}
+
+	// Tanpa publisher pesan dikembalikan ke queue
+	ack = &recordingAcknowledger{}
+	msg = amqp.Delivery{Acknowledger: ack, MessageId: "m1"}
+	if err := DeferMessage(nil, msg, "abc", config); err == nil {
+		t.Error("expected an error without a publisher")
+	}
+	if !ack.nacked || !ack.requeue || ack.acked {
+		t.Errorf("not deferred: ack=%+v, want nack with requeue", ack)
+	}
+}
@@ -0,0 +1,317 @@
+package rabbitmq
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/streadway/amqp"
+)
+
+const (
+	// DefaultPublisherPoolSize adalah jumlah maksimal channel confirm-mode yang disimpan di pool
+	DefaultPublisherPoolSize = 8
+
+	// DefaultConfirmTimeout adalah batas waktu menunggu confirm dari broker
+	DefaultConfirmTimeout = 5 * time.Second
+)
+
+var (
+	// ErrUnroutable dikembalikan jika pesan mandatory dikembalikan broker karena tidak ada queue tujuan
+	ErrUnroutable = errors.New("message returned by broker: no queue for routing key")
+
+	// ErrNacked dikembalikan jika broker menolak (nack) pesan
+	ErrNacked = errors.New("message nacked by broker")
+
+	// ErrConfirmTimeout dikembalikan jika confirm dari broker tidak diterima tepat waktu
+	ErrConfirmTimeout = errors.New("timed out waiting for publisher confirm")
+)
+
+// Publisher mempublikasikan pesan melalui pool channel dalam confirm mode.
+// Setiap pesan dipublikasikan dengan mandatory=true sehingga pesan yang tidak
+// dapat di-route dikembalikan broker (NotifyReturn) dan dilaporkan sebagai ErrUnroutable.
+type Publisher struct {
+	conn           *Connection
+	pool           chan *confirmChannel
+	confirmTimeout time.Duration
+}
+
+// publishChannel adalah bagian dari *amqp.Channel yang dipakai confirmChannel setelah dibuka
+type publishChannel interface {
+	Publish(exchange, key string, mandatory, immediate bool, msg amqp.Publishing) error
+	Close() error
+}
+
+// confirmChannel adalah channel dalam confirm mode beserta listener confirm dan return-nya
+type confirmChannel struct {
+	ch       publishChannel
+	confirms chan amqp.Confirmation
+	returns  chan amqp.Return
+	closed   chan *amqp.Error
+}
+
+// NewPublisher membuat Publisher baru. Nilai <= 0 memakai default.
+func NewPublisher(conn *Connection, poolSize int, confirmTimeout time.Duration) *Publisher {
+	if poolSize <= 0 {
+		poolSize = DefaultPublisherPoolSize
+	}
+	if confirmTimeout <= 0 {
+		confirmTimeout = DefaultConfirmTimeout
+	}
+
+	return &Publisher{
+		conn:           conn,
+		pool:           make(chan *confirmChannel, poolSize),
+		confirmTimeout: confirmTimeout,
+	}
+}
+
+// Publish mempublikasikan satu pesan dan menunggu confirm dari broker
+func (p *Publisher) Publish(ctx context.Context, exchange, routingKey string, msg amqp.Publishing) error {
+	cc, err := p.acquire()
+	if err != nil {
+		return err
+	}
+
+	err = cc.publish(ctx, exchange, routingKey, msg, p.confirmTimeout)
+	p.release(cc, err)
+
+	return err
+}
+
+// PublishBatch mempublikasikan beberapa pesan pada satu channel confirm-mode dan
+// mengembalikan error per pesan (nil jika pesan dikonfirmasi). Pesan yang dikembalikan
+// broker dicocokkan berdasarkan MessageId, sehingga setiap pesan harus memiliki MessageId
+// yang unik di dalam batch.
+func (p *Publisher) PublishBatch(ctx context.Context, exchange, routingKey string, msgs []amqp.Publishing) []error {
+	errs := make([]error, len(msgs))
+
+	cc, err := p.acquire()
+	if err != nil {
+		for i := range errs {
+			errs[i] = err
+		}
+		return errs
+	}
+
+	err = cc.publishBatch(ctx, exchange, routingKey, msgs, errs, p.confirmTimeout)
+	p.release(cc, err)
+
+	return errs
+}
+
+// acquire mengambil channel dari pool atau membuka channel baru jika pool kosong
+func (p *Publisher) acquire() (*confirmChannel, error) {
+	for {
+		select {
+		case cc := <-p.pool:
+			if cc.isClosed() {
+				// Channel mati (mis. setelah reconnect), buang dan ambil berikutnya
+				continue
+			}
+			return cc, nil
+		default:
+			return p.open()
+		}
+	}
+}
+
+// release mengembalikan channel ke pool. Channel dengan state tidak pasti
+// (timeout, context dibatalkan, atau channel ditutup) tidak dipakai ulang.
+func (p *Publisher) release(cc *confirmChannel, err error) {
+	if err != nil && !errors.Is(err, ErrUnroutable) && !errors.Is(err, ErrNacked) {
+		cc.ch.Close()
+		return
+	}
+	if cc.isClosed() {
+		return
+	}
+
+	select {
+	case p.pool <- cc:
+	default:
+		// Pool penuh
+		cc.ch.Close()
+	}
+}
+
+// open membuka channel baru dalam confirm mode
+func (p *Publisher) open() (*confirmChannel, error) {
+	ch, err := p.conn.Channel()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open publisher channel: %w", err)
+	}
+
+	if err := ch.Confirm(false); err != nil {
+		ch.Close()
+		return nil, fmt.Errorf("failed to enable publisher confirms: %w", err)
+	}
+
+	return &confirmChannel{
+		ch:       ch,
+		confirms: ch.NotifyPublish(make(chan amqp.Confirmation, 1)),
+		returns:  ch.NotifyReturn(make(chan amqp.Return, 1)),
+		closed:   ch.NotifyClose(make(chan *amqp.Error, 1)),
+	}, nil
+}
+
+// isClosed mengembalikan true jika channel sudah ditutup
+func (cc *confirmChannel) isClosed() bool {
+	select {
+	case <-cc.closed:
+		return true
+	default:
+		return false
+	}
+}
+
+// publish mempublikasikan pesan mandatory lalu menunggu confirm. Broker mengirim
+// basic.return sebelum basic.ack, sehingga return sudah tersedia saat confirm diterima.
+func (cc *confirmChannel) publish(ctx context.Context, exchange, routingKey string, msg amqp.Publishing, timeout time.Duration) error {
+	if err := cc.ch.Publish(exchange, routingKey, true, false, msg); err != nil {
+		return fmt.Errorf("failed to publish message: %w", err)
+	}
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	select {
+	case confirm, ok := <-cc.confirms:
+		if !ok {
+			return fmt.Errorf("publisher channel closed before confirm")
+		}
+		if !confirm.Ack {
+			return ErrNacked
+		}
+	case <-timer.C:
+		return ErrConfirmTimeout
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	select {
+	case ret := <-cc.returns:
+		return fmt.Errorf("%w: %s (%d %s)", ErrUnroutable, ret.RoutingKey, ret.ReplyCode, ret.ReplyText)
+	default:
+		return nil
+	}
+}
+
+// publishBatch mempublikasikan msgs secara berurutan sambil membaca confirm dan return
+// secara paralel agar reader koneksi tidak terblokir. Hasil per pesan ditulis ke errs.
+// timeout adalah batas waktu menunggu confirm berikutnya, bukan seluruh batch.
+// Error yang dikembalikan menandakan state channel tidak pasti sehingga tidak boleh dipakai ulang.
+func (cc *confirmChannel) publishBatch(ctx context.Context, exchange, routingKey string, msgs []amqp.Publishing, errs []error, timeout time.Duration) error {
+	// Kumpulkan pesan yang dikembalikan broker berdasarkan MessageId
+	var (
+		returnsMu sync.Mutex
+		returned  = make(map[string]amqp.Return)
+		stop      = make(chan struct{})
+		wg        sync.WaitGroup
+	)
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for {
+			select {
+			case ret, ok := <-cc.returns:
+				if !ok {
+					return
+				}
+				returnsMu.Lock()
+				returned[ret.MessageId] = ret
+				returnsMu.Unlock()
+			case <-stop:
+				// Return yang sudah ada di buffer sebelum confirm terakhir tetap dicatat
+				for {
+					select {
+					case ret, ok := <-cc.returns:
+						if !ok {
+							return
+						}
+						returnsMu.Lock()
+						returned[ret.MessageId] = ret
+						returnsMu.Unlock()
+					default:
+						return
+					}
+				}
+			}
+		}
+	}()
+
+	// Confirm diterima dalam urutan publish, sehingga confirm ke-n milik pesan ke-n
+	pending := make(chan int, len(msgs))
+	confirmed := make(chan error, 1)
+	go func() {
+		var fatal error
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+
+		for i := range pending {
+			if fatal != nil {
+				errs[i] = fatal
+				continue
+			}
+
+			select {
+			case confirm, ok := <-cc.confirms:
+				switch {
+				case !ok:
+					fatal = fmt.Errorf("publisher channel closed before confirm")
+					errs[i] = fatal
+				case !confirm.Ack:
+					errs[i] = ErrNacked
+				}
+				// Timeout berlaku per confirm, sehingga batch besar yang terus
+				// mendapat confirm tidak gagal hanya karena total waktunya
+				timer.Reset(timeout)
+			case <-timer.C:
+				fatal = ErrConfirmTimeout
+				errs[i] = fatal
+			case <-ctx.Done():
+				fatal = ctx.Err()
+				errs[i] = fatal
+			}
+		}
+		confirmed <- fatal
+	}()
+
+	var publishErr error
+	for i, msg := range msgs {
+		if publishErr != nil {
+			errs[i] = publishErr
+			continue
+		}
+		if err := cc.ch.Publish(exchange, routingKey, true, false, msg); err != nil {
+			publishErr = fmt.Errorf("failed to publish message: %w", err)
+			errs[i] = publishErr
+			continue
+		}
+		pending <- i
+	}
+	close(pending)
+
+	fatal := <-confirmed
+
+	// Return selalu dikirim broker sebelum confirm pesan yang sama, sehingga
+	// semua return sudah diterima collector saat semua confirm terbaca
+	close(stop)
+	wg.Wait()
+
+	for i, msg := range msgs {
+		if errs[i] != nil {
+			continue
+		}
+		if ret, ok := returned[msg.MessageId]; ok {
+			errs[i] = fmt.Errorf("%w: %s (%d %s)", ErrUnroutable, ret.RoutingKey, ret.ReplyCode, ret.ReplyText)
+		}
+	}
+
+	if publishErr != nil {
+		return publishErr
+	}
+	return fatal
+}
@@ -0,0 +1,178 @@
+package rabbitmq
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/streadway/amqp"
+)
+
+// Hasil yang dikirim fakeChannel untuk sebuah pesan, berdasarkan MessageId-nya
+const (
+	outcomeAck    = ""
+	outcomeNack   = "nack"
+	outcomeReturn = "return"
+	outcomeDrop   = "drop"
+)
+
+// fakeChannel mensimulasikan broker: setiap Publish dijawab dengan return (jika ada)
+// lalu confirm, setelah jeda delay. Pesan dengan outcomeDrop tidak pernah dikonfirmasi.
+type fakeChannel struct {
+	delay    time.Duration
+	outcomes map[string]string
+	confirms chan amqp.Confirmation
+	returns  chan amqp.Return
+	tag      uint64
+	closed   bool
+
+	// routingKeys dan published mencatat setiap pesan yang dipublikasikan
+	routingKeys []string
+	published   []amqp.Publishing
+}
+
+func newFakeChannel(delay time.Duration, outcomes map[string]string) *fakeChannel {
+	return &fakeChannel{
+		delay:    delay,
+		outcomes: outcomes,
+		confirms: make(chan amqp.Confirmation, 16),
+		returns:  make(chan amqp.Return, 16),
+	}
+}
+
+func (f *fakeChannel) Publish(exchange, key string, mandatory, immediate bool, msg amqp.Publishing) error {
+	time.Sleep(f.delay)
+
+	f.routingKeys = append(f.routingKeys, key)
+	f.published = append(f.published, msg)
+	f.tag++
+	switch f.outcomes[msg.MessageId] {
+	case outcomeDrop:
+		return nil
+	case outcomeReturn:
+		f.returns <- amqp.Return{MessageId: msg.MessageId, RoutingKey: key, ReplyCode: 312, ReplyText: "NO_ROUTE"}
+		f.confirms <- amqp.Confirmation{DeliveryTag: f.tag, Ack: true}
+	case outcomeNack:
+		f.confirms <- amqp.Confirmation{DeliveryTag: f.tag, Ack: false}
+	default:
+		f.confirms <- amqp.Confirmation{DeliveryTag: f.tag, Ack: true}
+	}
+	return nil
+}
+
+func (f *fakeChannel) Close() error {
+	f.closed = true
+	return nil
+}
+
+func (f *fakeChannel) confirmChannel() *confirmChannel {
+	return &confirmChannel{
+		ch:       f,
+		confirms: f.confirms,
+		returns:  f.returns,
+		closed:   make(chan *amqp.Error),
+	}
+}
+
+func TestConfirmChannelPublish(t *testing.T) {
+	f := newFakeChannel(0, map[string]string{
+		"nacked":   outcomeNack,
+		"returned": outcomeReturn,
+		"dropped":  outcomeDrop,
+	})
+	cc := f.confirmChannel()
+	ctx := context.Background()
+
+	if err := cc.publish(ctx, "ex", "rk", amqp.Publishing{MessageId: "acked"}, time.Second); err != nil {
+		t.Errorf("acked: unexpected error: %v", err)
+	}
+	if err := cc.publish(ctx, "ex", "rk", amqp.Publishing{MessageId: "nacked"}, time.Second); !errors.Is(err, ErrNacked) {
+		t.Errorf("nacked: got %v, want ErrNacked", err)
+	}
+	if err := cc.publish(ctx, "ex", "rk", amqp.Publishing{MessageId: "returned"}, time.Second); !errors.Is(err, ErrUnroutable) {
+		t.Errorf("returned: got %v, want ErrUnroutable", err)
+	}
+	if err := cc.publish(ctx, "ex", "rk", amqp.Publishing{MessageId: "dropped"}, 20*time.Millisecond); !errors.Is(err, ErrConfirmTimeout) {
+		t.Errorf("dropped: got %v, want ErrConfirmTimeout", err)
+	}
+}
+
+func TestPublisherReleaseDiscardsUncertainChannels(t *testing.T) {
+	p := NewPublisher(nil, 1, 0)
+
+	reusable := newFakeChannel(0, nil)
+	p.release(reusable.confirmChannel(), ErrNacked)
+	if reusable.closed || len(p.pool) != 1 {
+		t.Errorf("channel after a nack should go back to the pool")
+	}
+
+	uncertain := newFakeChannel(0, nil)
+	p.release(uncertain.confirmChannel(), ErrConfirmTimeout)
+	if !uncertain.closed {
+		t.Errorf("channel after a confirm timeout should be closed")
+	}
+
+	full := newFakeChannel(0, nil)
+	p.release(full.confirmChannel(), nil)
+	if !full.closed {
+		t.Errorf("channel released into a full pool should be closed")
+	}
+}
+
+func TestConfirmChannelPublishBatch(t *testing.T) {
+	f := newFakeChannel(0, map[string]string{
+		"b": outcomeReturn,
+		"c": outcomeNack,
+	})
+	msgs := []amqp.Publishing{{MessageId: "a"}, {MessageId: "b"}, {MessageId: "c"}, {MessageId: "d"}}
+	errs := make([]error, len(msgs))
+
+	if err := f.confirmChannel().publishBatch(context.Background(), "ex", "rk", msgs, errs, time.Second); err != nil {
+		t.Fatalf("unexpected channel error: %v", err)
+	}
+
+	if errs[0] != nil || errs[3] != nil {
+		t.Errorf("confirmed messages: got %v and %v, want nil", errs[0], errs[3])
+	}
+	if !errors.Is(errs[1], ErrUnroutable) {
+		t.Errorf("returned message: got %v, want ErrUnroutable", errs[1])
+	}
+	if !errors.Is(errs[2], ErrNacked) {
+		t.Errorf("nacked message: got %v, want ErrNacked", errs[2])
+	}
+}
+
+func TestConfirmChannelPublishBatchTimeout(t *testing.T) {
+	// Setiap confirm tiba dalam timeout walaupun total batch melebihinya
+	f := newFakeChannel(10*time.Millisecond, nil)
+	msgs := make([]amqp.Publishing, 8)
+	errs := make([]error, len(msgs))
+
+	if err := f.confirmChannel().publishBatch(context.Background(), "ex", "rk", msgs, errs, 40*time.Millisecond); err != nil {
+		t.Fatalf("slow but steady confirms: unexpected channel error: %v", err)
+	}
+	for i, err := range errs {
+		if err != nil {
+			t.Errorf("slow but steady confirms: message %d: %v", i, err)
+		}
+	}
+
+	// Setelah confirm berhenti datang, sisa batch gagal dengan timeout
+	f = newFakeChannel(0, map[string]string{"b": outcomeDrop, "c": outcomeDrop})
+	msgs = []amqp.Publishing{{MessageId: "a"}, {MessageId: "b"}, {MessageId: "c"}}
+	errs = make([]error, len(msgs))
+
+	err := f.confirmChannel().publishBatch(context.Background(), "ex", "rk", msgs, errs, 20*time.Millisecond)
+	if !errors.Is(err, ErrConfirmTimeout) {
+		t.Fatalf("missing confirms: got %v, want ErrConfirmTimeout", err)
+	}
+	if errs[0] != nil {
+		t.Errorf("missing confirms: confirmed message: %v", errs[0])
+	}
+	for _, i := range []int{1, 2} {
+		if !errors.Is(errs[i], ErrConfirmTimeout) {
+			t.Errorf("missing confirms: message %d: got %v, want ErrConfirmTimeout", i, errs[i])
+		}
+	}
+}
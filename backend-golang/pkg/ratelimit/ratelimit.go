@@ -0,0 +1,119 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// ErrCostExceedsBurst dikembalikan jika satu request membutuhkan token lebih banyak dari burst
+var ErrCostExceedsBurst = errors.New("request cost exceeds rate limit burst")
+
+// Limit adalah konfigurasi token bucket: Rate token per detik dengan kapasitas Burst.
+// Rate <= 0 berarti tidak dibatasi.
+type Limit struct {
+	Rate  float64
+	Burst int
+}
+
+// Unlimited mengembalikan true jika limit tidak membatasi request
+func (l Limit) Unlimited() bool {
+	return l.Rate <= 0
+}
+
+// burst mengembalikan kapasitas bucket; jika tidak di-set, kapasitas = token per detik (minimal 1)
+func (l Limit) burst() int {
+	if l.Burst > 0 {
+		return l.Burst
+	}
+	return int(math.Max(1, math.Ceil(l.Rate)))
+}
+
+// tokenBucketScript mengisi ulang bucket berdasarkan waktu server Redis lalu mengambil
+// cost token secara atomik. Mengembalikan {allowed, retry_after_seconds}.
+var tokenBucketScript = redis.NewScript(`
+redis.replicate_commands()
+local rate = tonumber(ARGV[1])
+local burst = tonumber(ARGV[2])
+local cost = tonumber(ARGV[3])
+
+local t = redis.call('TIME')
+local now = tonumber(t[1]) + tonumber(t[2]) / 1000000
+
+local state = redis.call('HMGET', KEYS[1], 'tokens', 'ts')
+local tokens = tonumber(state[1])
+local ts = tonumber(state[2])
+if tokens == nil or ts == nil then
+	tokens = burst
+	ts = now
+end
+
+tokens = math.min(burst, tokens + math.max(0, now - ts) * rate)
+
+local allowed = 0
+local wait = 0
+if tokens >= cost then
+	tokens = tokens - cost
+	allowed = 1
+else
+	wait = (cost - tokens) / rate
+end
+
+redis.call('HSET', KEYS[1], 'tokens', tokens, 'ts', now)
+redis.call('EXPIRE', KEYS[1], math.ceil(burst / rate) + 1)
+
+return {allowed, tostring(wait)}
+`)
+
+// Limiter adalah token bucket yang disimpan di Redis sehingga beberapa replica
+// backend berbagi limit yang sama untuk key yang sama
+type Limiter struct {
+	client *redis.Client
+	prefix string
+}
+
+// NewLimiter membuat Limiter baru; prefix dipakai sebagai awalan key Redis
+func NewLimiter(client *redis.Client, prefix string) *Limiter {
+	return &Limiter{
+		client: client,
+		prefix: prefix,
+	}
+}
+
+// Allow mengambil cost token dari bucket key. Jika token tidak cukup, allowed bernilai
+// false dan retryAfter berisi perkiraan waktu sampai token cukup.
+func (l *Limiter) Allow(ctx context.Context, key string, limit Limit, cost int) (allowed bool, retryAfter time.Duration, err error) {
+	if limit.Unlimited() {
+		return true, 0, nil
+	}
+	if cost < 1 {
+		cost = 1
+	}
+
+	burst := limit.burst()
+	if cost > burst {
+		return false, 0, ErrCostExceedsBurst
+	}
+
+	result, err := tokenBucketScript.Run(ctx, l.client, []string{l.prefix + key}, limit.Rate, burst, cost).Slice()
+	if err != nil {
+		return false, 0, fmt.Errorf("failed to run rate limit script: %w", err)
+	}
+	if len(result) != 2 {
+		return false, 0, fmt.Errorf("unexpected rate limit script result: %v", result)
+	}
+
+	ok, _ := result[0].(int64)
+	waitStr, _ := result[1].(string)
+	wait, err := strconv.ParseFloat(waitStr, 64)
+	if err != nil {
+		return false, 0, fmt.Errorf("invalid rate limit retry after %q: %w", waitStr, err)
+	}
+
+	return ok == 1, time.Duration(wait * float64(time.Second)), nil
+}
@@ -0,0 +1,72 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+)
+
+func TestLimitBurst(t *testing.T) {
+	cases := map[Limit]int{
+		{Rate: 10, Burst: 50}: 50,
+		{Rate: 10}:            10,
+		{Rate: 2.5}:           3,
+		{Rate: 0.2}:           1,
+		{Rate: 4, Burst: -1}:  4,
+	}
+
+	for limit, want := range cases {
+		if got := limit.burst(); got != want {
+			t.Errorf("%+v.burst() = %d, want %d", limit, got, want)
+		}
+	}
+}
+
+func TestLimiterAllow(t *testing.T) {
+	mr := miniredis.RunT(t)
+	mr.SetTime(time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC))
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	defer client.Close()
+
+	l := NewLimiter(client, "ratelimit:")
+	limit := Limit{Rate: 2, Burst: 3}
+	ctx := context.Background()
+
+	for i := 0; i < 3; i++ {
+		allowed, _, err := l.Allow(ctx, "t1", limit, 1)
+		if err != nil || !allowed {
+			t.Fatalf("request %d within burst: allowed=%v err=%v", i+1, allowed, err)
+		}
+	}
+
+	allowed, retryAfter, err := l.Allow(ctx, "t1", limit, 1)
+	if err != nil || allowed {
+		t.Fatalf("request over burst: allowed=%v err=%v", allowed, err)
+	}
+	if retryAfter != 500*time.Millisecond {
+		t.Errorf("retryAfter = %s, want 500ms", retryAfter)
+	}
+
+	// Bucket tenant lain tidak terpengaruh
+	if allowed, _, err := l.Allow(ctx, "t2", limit, 3); err != nil || !allowed {
+		t.Errorf("other key: allowed=%v err=%v", allowed, err)
+	}
+
+	// Token terisi ulang sesuai rate
+	mr.SetTime(time.Date(2026, 1, 1, 12, 0, 1, 0, time.UTC))
+	if allowed, _, err := l.Allow(ctx, "t1", limit, 2); err != nil || !allowed {
+		t.Errorf("after refill: allowed=%v err=%v", allowed, err)
+	}
+
+	if _, _, err := l.Allow(ctx, "t1", limit, 4); !errors.Is(err, ErrCostExceedsBurst) {
+		t.Errorf("cost over burst: got %v, want ErrCostExceedsBurst", err)
+	}
+
+	if allowed, _, err := l.Allow(ctx, "t1", Limit{}, 100); err != nil || !allowed {
+		t.Errorf("unlimited: allowed=%v err=%v", allowed, err)
+	}
+}
@@ -0,0 +1,2 @@
+-- Remove prefetch column from tenants table
+ALTER TABLE tenants DROP COLUMN IF EXISTS prefetch;
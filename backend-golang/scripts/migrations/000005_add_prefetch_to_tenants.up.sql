@@ -0,0 +1,2 @@
+-- Add prefetch column to tenants table
+ALTER TABLE tenants ADD COLUMN IF NOT EXISTS prefetch INTEGER NOT NULL DEFAULT 10;
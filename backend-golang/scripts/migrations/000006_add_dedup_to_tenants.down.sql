@@ -0,0 +1,2 @@
+-- Remove dedup_enabled column from tenants table
+ALTER TABLE tenants DROP COLUMN IF EXISTS dedup_enabled;
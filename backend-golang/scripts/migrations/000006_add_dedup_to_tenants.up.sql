@@ -0,0 +1,2 @@
+-- Add dedup_enabled column to tenants table
+ALTER TABLE tenants ADD COLUMN IF NOT EXISTS dedup_enabled BOOLEAN NOT NULL DEFAULT FALSE;
@@ -0,0 +1,3 @@
+-- Remove publish rate limit columns from tenants table
+ALTER TABLE tenants DROP COLUMN IF EXISTS publish_burst;
+ALTER TABLE tenants DROP COLUMN IF EXISTS publish_rate;
@@ -0,0 +1,3 @@
+-- Add publish rate limit columns to tenants table (0 = use the configured default)
+ALTER TABLE tenants ADD COLUMN IF NOT EXISTS publish_rate DOUBLE PRECISION NOT NULL DEFAULT 0;
+ALTER TABLE tenants ADD COLUMN IF NOT EXISTS publish_burst INTEGER NOT NULL DEFAULT 0;
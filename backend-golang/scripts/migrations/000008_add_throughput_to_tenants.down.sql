@@ -0,0 +1,3 @@
+-- Remove consumption rate limit columns from tenants table
+ALTER TABLE tenants DROP COLUMN IF EXISTS throughput_burst;
+ALTER TABLE tenants DROP COLUMN IF EXISTS throughput_rate;
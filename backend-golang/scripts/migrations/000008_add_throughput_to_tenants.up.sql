@@ -0,0 +1,3 @@
+-- Add consumption rate limit columns to tenants table (0 = unlimited)
+ALTER TABLE tenants ADD COLUMN IF NOT EXISTS throughput_rate DOUBLE PRECISION NOT NULL DEFAULT 0;
+ALTER TABLE tenants ADD COLUMN IF NOT EXISTS throughput_burst INTEGER NOT NULL DEFAULT 0;
@@ -0,0 +1,2 @@
+-- Remove the tenant status constraint
+ALTER TABLE tenants DROP CONSTRAINT IF EXISTS tenants_status_check;
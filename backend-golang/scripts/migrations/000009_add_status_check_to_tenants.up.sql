@@ -0,0 +1,6 @@
+-- Map free-form statuses to the tenant state machine and restrict the column to its states
+UPDATE tenants SET status = 'active'
+WHERE status NOT IN ('provisioning', 'active', 'paused', 'suspended', 'deleting', 'failed');
+
+ALTER TABLE tenants ADD CONSTRAINT tenants_status_check
+    CHECK (status IN ('provisioning', 'active', 'paused', 'suspended', 'deleting', 'failed'));
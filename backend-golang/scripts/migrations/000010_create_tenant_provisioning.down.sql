@@ -0,0 +1,3 @@
+-- Drop provisioning outbox and status reason
+DROP TABLE IF EXISTS tenant_provisioning;
+ALTER TABLE tenants DROP COLUMN IF EXISTS status_reason;
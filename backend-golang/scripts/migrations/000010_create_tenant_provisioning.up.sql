@@ -0,0 +1,18 @@
+-- Reason for the current tenant status, e.g. the provisioning step that failed
+ALTER TABLE tenants ADD COLUMN IF NOT EXISTS status_reason TEXT NOT NULL DEFAULT '';
+
+-- Provisioning outbox: one row per provisioning step, written in the same transaction as the tenant
+CREATE TABLE IF NOT EXISTS tenant_provisioning (
+    tenant_id UUID NOT NULL REFERENCES tenants(id) ON DELETE CASCADE,
+    step VARCHAR(50) NOT NULL,
+    position INTEGER NOT NULL,
+    state VARCHAR(20) NOT NULL DEFAULT 'pending',
+    attempts INTEGER NOT NULL DEFAULT 0,
+    last_error TEXT NOT NULL DEFAULT '',
+    next_attempt_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (tenant_id, step)
+);
+
+-- Index untuk mencari step yang masih pending oleh reconciler
+CREATE INDEX IF NOT EXISTS idx_tenant_provisioning_pending ON tenant_provisioning(next_attempt_at) WHERE state = 'pending';
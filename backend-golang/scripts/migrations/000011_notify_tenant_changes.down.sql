@@ -0,0 +1,3 @@
+-- Remove the tenant change notification trigger
+DROP TRIGGER IF EXISTS tenants_notify_change ON tenants;
+DROP FUNCTION IF EXISTS notify_tenant_change();
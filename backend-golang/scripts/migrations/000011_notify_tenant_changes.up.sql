@@ -0,0 +1,25 @@
+-- Notify every replica when a tenant row changes so running consumers pick up
+-- concurrency, prefetch, dedup, throughput and status changes without a restart
+CREATE OR REPLACE FUNCTION notify_tenant_change()
+RETURNS trigger AS $$
+DECLARE
+    tenant_id UUID;
+BEGIN
+    IF TG_OP = 'DELETE' THEN
+        tenant_id := OLD.id;
+    ELSE
+        tenant_id := NEW.id;
+    END IF;
+
+    PERFORM pg_notify(
+        'tenant_changes',
+        json_build_object('tenant_id', tenant_id, 'op', TG_OP)::text
+    );
+
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER tenants_notify_change
+    AFTER INSERT OR UPDATE OR DELETE ON tenants
+    FOR EACH ROW EXECUTE FUNCTION notify_tenant_change();
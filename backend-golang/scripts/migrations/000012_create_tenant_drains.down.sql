@@ -0,0 +1,2 @@
+-- Drop drain-then-delete progress
+DROP TABLE IF EXISTS tenant_drains;
@@ -0,0 +1,17 @@
+-- Drain-then-delete progress, written in the same transaction that marks the tenant as deleting.
+-- The row is removed together with the tenant; aborted drains keep their row for reporting.
+CREATE TABLE IF NOT EXISTS tenant_drains (
+    tenant_id UUID PRIMARY KEY REFERENCES tenants(id) ON DELETE CASCADE,
+    previous_status VARCHAR(20) NOT NULL,
+    state VARCHAR(20) NOT NULL DEFAULT 'draining',
+    pending_messages INTEGER NOT NULL DEFAULT 0,
+    empty_checks INTEGER NOT NULL DEFAULT 0,
+    started_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    deadline TIMESTAMP NOT NULL,
+    finished_at TIMESTAMP,
+    error TEXT NOT NULL DEFAULT '',
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Index untuk mencari drain yang masih berjalan oleh reconciler
+CREATE INDEX IF NOT EXISTS idx_tenant_drains_draining ON tenant_drains(deadline) WHERE state = 'draining';
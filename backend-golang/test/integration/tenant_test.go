@@ -11,10 +11,13 @@ import (
 	"github.com/streadway/amqp"
 
 	"github.com/jatis/sample-stack-golang/internal/config"
+	messagePostgresql "github.com/jatis/sample-stack-golang/internal/modules/message/repository/postgresql"
 	"github.com/jatis/sample-stack-golang/internal/modules/tenant/domain"
 	"github.com/jatis/sample-stack-golang/internal/modules/tenant/repository/postgresql"
 	"github.com/jatis/sample-stack-golang/internal/modules/tenant/usecase"
 	"github.com/jatis/sample-stack-golang/internal/modules/tenant/delivery/messaging/rabbitmq"
+	"github.com/jatis/sample-stack-golang/internal/modules/tenant/delivery/messaging/rabbitmq/consumer"
+	pkgRabbitMQ "github.com/jatis/sample-stack-golang/pkg/rabbitmq"
 	"github.com/jatis/sample-stack-golang/test/integration/setup"
 )
 
@@ -37,7 +40,9 @@ func TestTenantIntegration(t *testing.T) {
 
 	// Create repositories and services
 	tenantRepo := postgresql.NewTenantRepository(connections.DB, cfg)
-	tenantManager := rabbitmq.NewTenantManager(connections.RabbitMQ, connections.DB)
+	messageRepo := messagePostgresql.NewMessageRepository(connections.DB)
+	messageHandlers := consumer.NewHandlerRegistry(consumer.NewPersistHandler(messageRepo))
+	tenantManager := rabbitmq.NewTenantManager(pkgRabbitMQ.NewConnection(connections.RabbitMQ, nil), connections.DB, messageHandlers, nil, nil, config.ConsumerConfig{})
 	tenantUseCase := usecase.NewTenantUseCase(tenantRepo, tenantManager)
 
 	// Test cases
@@ -185,7 +190,7 @@ func TestTenantIntegration(t *testing.T) {
 		// Get consumer
 		consumer := tenantUseCase.GetConsumer(tenant.ID)
 		assert.NotNil(t, consumer)
-		assert.True(t, consumer.IsActive)
+		assert.True(t, consumer.IsActive.Load())
 
 		// Stop consumer
 		err = tenantUseCase.StopConsumer(ctx, tenant.ID)